@@ -2,52 +2,127 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
-	"time"
-
-	"github.com/dshipenok/tickers/pkg"
-	"github.com/dshipenok/tickers/pkg/collector"
+	"strings"
+	"sync/atomic"
+	"syscall"
 )
 
-const preiod = time.Second * 5
+// exitInterrupted is exit code of commands stopped by interrupt signal, gracefully or not
+const exitInterrupted = 130
+
+// interrupted is set to 1 when interrupt signal was received
+var interrupted int32
+
+// version is set on build: go build -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// usageError means wrong arguments or config, such errors exit with code 2
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+var commands = []command{
+	{name: "run", usage: "run the fair price pipeline (default)", run: runCommand},
+	{name: "record", usage: "record source prices into a file", run: recordCommand},
+	{name: "replay", usage: "run the pipeline over recorded prices", run: replayCommand},
+	{name: "query", usage: "print stored fair prices", run: queryCommand},
+	{name: "validate-config", usage: "check config file and exit", run: validateConfigCommand},
+	{name: "version", usage: "print version", run: versionCommand},
+}
 
 func main() {
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-
-	apis := []pkg.IPriceStreamSubscriber{
-		pkg.NewMockRandomStream(),
-		pkg.NewMockRandomStream(),
-		pkg.NewMockRandomStream(),
-		pkg.NewMockRandomStream(),
-		pkg.NewMockRandomStream(),
+	os.Exit(execute(os.Args[1:]))
+}
+
+func execute(args []string) int {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		printUsage()
+		return 0
 	}
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(args)
+		var usageErr usageError
+		switch {
+		case err == nil && atomic.LoadInt32(&interrupted) == 1:
+			return exitInterrupted
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.As(err, &usageErr):
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		default:
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+	printUsage()
+	return 2
+}
 
-	m := pkg.NewMultiplexor()
-	output := m.Subscribe(apis)
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: fairprice <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'fairprice <command> -h' for command flags.")
+}
 
-	c := collector.NewLatest(3)
-	p := pkg.NewFairPrice(c, time.Now)
+// interruptContext is cancelled on interrupt signal to start graceful shutdown.
+// The second signal terminates the process immediately.
+func interruptContext() (context.Context, context.CancelFunc) {
+	interrupt := make(chan os.Signal, 2)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
-		<-interrupt
-		cancel()
-	}()
-	outputCh := make(chan pkg.TickerPrice, 1)
-	defer close(outputCh)
-	go func() {
-		for p := range outputCh {
-			outputValue(p.Price)
+		select {
+		case <-interrupt:
+			atomic.StoreInt32(&interrupted, 1)
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(interrupt)
+			return
 		}
+		fmt.Fprintln(os.Stderr, "shutting down, interrupt again to force")
+		<-interrupt
+		os.Exit(exitInterrupted)
 	}()
-	p.Start(ctx, output, preiod, outputCh)
+	return ctx, cancel
 }
 
-func outputValue(value string) {
-	tm := time.Now()
-	timeStr := tm.Format("02/01 15:04:05")
-	fmt.Println(timeStr+",", value)
+func versionCommand(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return usageError{err}
+	}
+	fmt.Println("fairprice", version)
+	return nil
 }
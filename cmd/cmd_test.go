@@ -0,0 +1,35 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Execute_ExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	tsts := []struct {
+		desc        string
+		args        []string
+		interrupted bool
+		expect      int
+	}{
+		{desc: "success", args: []string{"version"}, expect: 0},
+		{desc: "help", args: []string{"help"}, expect: 0},
+		{desc: "help flag", args: []string{"version", "-h"}, expect: 0},
+		{desc: "unknown command", args: []string{"serve"}, expect: 2},
+		{desc: "usage error", args: []string{"validate-config"}, expect: 2},
+		{desc: "runtime error", args: []string{"query", "-store", dir, "-at", "2020-01-07T14:00:00Z"}, expect: 1},
+		{desc: "interrupted", args: []string{"version"}, interrupted: true, expect: exitInterrupted},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.desc, func(t *testing.T) {
+			if tst.interrupted {
+				atomic.StoreInt32(&interrupted, 1)
+				defer atomic.StoreInt32(&interrupted, 0)
+			}
+			assert.Equal(t, tst.expect, execute(tst.args))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/dshipenok/tickers/pkg/config"
+)
+
+// pipelineFlags override config values when set explicitly
+type pipelineFlags struct {
+	fs *flag.FlagSet
+
+	config    string
+	period    time.Duration
+	collector string
+	precision int
+	format    string
+}
+
+func newPipelineFlags(name string) *pipelineFlags {
+	f := &pipelineFlags{fs: flag.NewFlagSet(name, flag.ContinueOnError)}
+	f.fs.StringVar(&f.config, "config", "", "path to YAML config, built-in defaults are used if empty")
+	f.fs.DurationVar(&f.period, "period", 0, "fair price period, e.g. 5s")
+	f.fs.StringVar(&f.collector, "collector", "", "collector strategy, one of: "+strings.Join(collector.Names(), ", "))
+	f.fs.IntVar(&f.precision, "precision", 0, "number of digits after decimal point")
+	f.fs.StringVar(&f.format, "format", "", "stdout format: text, json or csv")
+	return f
+}
+
+func (f *pipelineFlags) parse(args []string) error {
+	if err := f.fs.Parse(args); err != nil {
+		return usageError{err}
+	}
+	if f.fs.NArg() > 0 {
+		return usageError{errUnexpectedArgs(f.fs.Args())}
+	}
+	return nil
+}
+
+// load reads config and applies flags on top of it
+func (f *pipelineFlags) load() (*config.Config, error) {
+	cfg := config.Default()
+	if f.config != "" {
+		var err error
+		if cfg, err = config.Load(f.config); err != nil {
+			return nil, usageError{err}
+		}
+	}
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "period":
+			cfg.Period = f.period
+		case "collector":
+			// options of the strategy from config don't fit another one
+			cfg.Collector = config.CollectorConfig{Strategy: f.collector, Precision: cfg.Collector.Precision}
+		case "precision":
+			cfg.Collector.Precision = &f.precision
+		case "format":
+			for i := range cfg.Outputs {
+				if cfg.Outputs[i].Type == config.OutputStdout {
+					cfg.Outputs[i].Format = f.format
+				}
+			}
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		return nil, usageError{err}
+	}
+	return cfg, nil
+}
+
+func errUnexpectedArgs(args []string) error {
+	return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+}
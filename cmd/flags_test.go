@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/dshipenok/tickers/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const flagsConfig = `
+tickers: [BTC_USD]
+period: 10s
+collector:
+  strategy: average
+  precision: 5
+sources: [{name: a, type: random}]
+outputs: [{type: stdout, format: json}]
+`
+
+func Test_PipelineFlags(t *testing.T) {
+	tsts := []struct {
+		desc   string
+		config string // written to a file passed with -config if not empty
+		args   []string
+		err    bool
+		check  func(t *testing.T, cfg *config.Config)
+	}{
+		{
+			desc: "defaults",
+			check: func(t *testing.T, cfg *config.Config) {
+				assert.Equal(t, 5*time.Second, cfg.Period)
+				assert.Equal(t, collector.NameLatest, cfg.Collector.Strategy)
+				assert.Equal(t, config.FormatText, cfg.Outputs[0].Format)
+			},
+		},
+		{
+			desc:   "config values",
+			config: flagsConfig,
+			check: func(t *testing.T, cfg *config.Config) {
+				assert.Equal(t, 10*time.Second, cfg.Period)
+				assert.Equal(t, collector.NameAverage, cfg.Collector.Strategy)
+				assert.Equal(t, 5, *cfg.Collector.Precision)
+				assert.Equal(t, config.FormatJSON, cfg.Outputs[0].Format)
+			},
+		},
+		{
+			desc:   "flags override config",
+			config: flagsConfig,
+			args:   []string{"-period", "1m", "-precision", "2", "-format", "csv"},
+			check: func(t *testing.T, cfg *config.Config) {
+				assert.Equal(t, time.Minute, cfg.Period)
+				assert.Equal(t, 3*time.Minute, cfg.StaleAfter(), "derived from overridden period")
+				assert.Equal(t, collector.NameAverage, cfg.Collector.Strategy)
+				assert.Equal(t, 2, *cfg.Collector.Precision)
+				assert.Equal(t, config.FormatCSV, cfg.Outputs[0].Format)
+			},
+		},
+		{
+			desc:   "collector flag keeps precision of config",
+			config: flagsConfig,
+			args:   []string{"-collector", "median"},
+			check: func(t *testing.T, cfg *config.Config) {
+				assert.Equal(t, collector.NameMedian, cfg.Collector.Strategy)
+				assert.Equal(t, 5, *cfg.Collector.Precision)
+			},
+		},
+		{desc: "unknown collector", args: []string{"-collector", "best"}, err: true},
+		{desc: "unknown format", args: []string{"-format", "xml"}, err: true},
+		{desc: "non-positive period", args: []string{"-period", "0s"}, err: true},
+		{desc: "unknown flag", args: []string{"-speed", "2"}, err: true},
+		{desc: "unexpected args", args: []string{"extra"}, err: true},
+		{desc: "missing config", args: []string{"-config", "missing.yaml"}, err: true},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.desc, func(t *testing.T) {
+			args := tst.args
+			if tst.config != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				require.NoError(t, os.WriteFile(path, []byte(tst.config), 0o600))
+				args = append([]string{"-config", path}, args...)
+			}
+			flags := newPipelineFlags("test")
+			flags.fs.SetOutput(io.Discard)
+			var cfg *config.Config
+			err := flags.parse(args)
+			if err == nil {
+				cfg, err = flags.load()
+			}
+			if tst.err {
+				require.Error(t, err)
+				assert.True(t, errors.As(err, &usageError{}), "usage error is expected, got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			tst.check(t, cfg)
+		})
+	}
+}
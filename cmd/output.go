@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/config"
+	"github.com/dshipenok/tickers/pkg/httpapi"
+	"github.com/dshipenok/tickers/pkg/store"
+)
+
+type priceWriter interface {
+	Write(pkg.FairPriceResult) error
+}
+
+// openOutputs creates writers of configured outputs, returned function releases them
+func openOutputs(outputs []config.OutputConfig) ([]priceWriter, func() error, error) {
+	writers := make([]priceWriter, 0, len(outputs))
+	stores := []*store.Store{}
+	closeAll := func() error {
+		var result error
+		for _, s := range stores {
+			if err := s.Close(); err != nil && result == nil {
+				result = err
+			}
+		}
+		return result
+	}
+	for _, output := range outputs {
+		switch output.Type {
+		case config.OutputStore:
+			s, err := store.Open(output.Path, store.DefaultSegmentSize)
+			if err != nil {
+				closeAll()
+				return nil, nil, fmt.Errorf("store output: %w", err)
+			}
+			stores = append(stores, s)
+			writers = append(writers, s)
+		default:
+			w, err := newPriceWriter(output.Format, os.Stdout)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			writers = append(writers, w)
+		}
+	}
+	return writers, closeAll, nil
+}
+
+func newPriceWriter(format string, w io.Writer) (priceWriter, error) {
+	switch format {
+	case config.FormatText:
+		return &textWriter{w: w}, nil
+	case config.FormatJSON:
+		return &jsonWriter{enc: json.NewEncoder(w)}, nil
+	case config.FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type textWriter struct {
+	w io.Writer
+}
+
+func (t *textWriter) Write(price pkg.FairPriceResult) error {
+	timeStr := time.Now().Format("02/01 15:04:05")
+	_, err := fmt.Fprintln(t.w, timeStr+",", string(price.Ticker)+",", price.Price)
+	return err
+}
+
+type jsonWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonWriter) Write(price pkg.FairPriceResult) error {
+	return j.enc.Encode(httpapi.NewPriceView(price))
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(price pkg.FairPriceResult) error {
+	if !c.headerWritten {
+		c.headerWritten = true
+		if err := c.w.Write([]string{"time", "ticker", "value", "status", "period_start", "period_end", "sources", "partial",
+			"open", "high", "low", "close", "ticks", "volume", "impact_bid", "impact_ask", "group"}); err != nil {
+			return err
+		}
+	}
+	view := httpapi.NewPriceView(price)
+	value := ""
+	if view.Value != nil {
+		value = *view.Value
+	}
+	candle := []string{"", "", "", "", "", ""}
+	if view.Candle != nil {
+		candle = []string{view.Candle.Open, view.Candle.High, view.Candle.Low, view.Candle.Close,
+			strconv.Itoa(view.Candle.Ticks), view.Candle.Volume}
+	}
+	impact := []string{"", ""}
+	if view.Impact != nil {
+		impact = []string{view.Impact.Bid, view.Impact.Ask}
+	}
+	err := c.w.Write(append(append([]string{
+		view.Time.Format(time.RFC3339Nano),
+		string(view.Ticker),
+		value,
+		string(view.Status),
+		view.PeriodStart.Format(time.RFC3339Nano),
+		view.PeriodEnd.Format(time.RFC3339Nano),
+		strconv.Itoa(view.Sources),
+		strconv.FormatBool(view.Partial),
+	}, candle...), append(impact, view.Group)...))
+	if err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
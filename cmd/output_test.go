@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/dshipenok/tickers/pkg/config"
+	"github.com/dshipenok/tickers/pkg/httpapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tn = time.Date(2020, 1, 7, 14, 0, 0, 0, time.UTC)
+
+func outputPrices() []pkg.FairPriceResult {
+	return []pkg.FairPriceResult{
+		{
+			TickerPrice: pkg.TickerPrice{Ticker: pkg.BTCUSDTicker, Time: tn, Price: "1.500"},
+			Status:      pkg.StatusOK,
+			PeriodStart: tn.Add(-5 * time.Second),
+			PeriodEnd:   tn,
+			SourceCount: 2,
+			Candle:      &collector.Candle{Open: "1", High: "2", Low: "1", Close: "1.500", Ticks: 3, Volume: "10"},
+		},
+		{
+			TickerPrice: pkg.TickerPrice{Ticker: "ETH_USD", Time: tn, Price: collector.NoValue},
+			Status:      pkg.StatusNoValue,
+			PeriodStart: tn.Add(-5 * time.Second),
+			PeriodEnd:   tn,
+			Partial:     true,
+		},
+	}
+}
+
+func writeAll(t *testing.T, format string) string {
+	buf := &bytes.Buffer{}
+	w, err := newPriceWriter(format, buf)
+	require.NoError(t, err)
+	for _, price := range outputPrices() {
+		require.NoError(t, w.Write(price))
+	}
+	return buf.String()
+}
+
+func Test_Writers(t *testing.T) {
+	tsts := []struct {
+		format string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			format: config.FormatText,
+			check: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				require.Len(t, lines, 2)
+				assert.True(t, strings.HasSuffix(lines[0], ", BTC_USD, 1.500"), lines[0])
+				assert.True(t, strings.HasSuffix(lines[1], ", ETH_USD, no value"), lines[1])
+			},
+		},
+		{
+			format: config.FormatJSON,
+			check: func(t *testing.T, out string) {
+				dec := json.NewDecoder(strings.NewReader(out))
+				var first, second httpapi.PriceView
+				require.NoError(t, dec.Decode(&first))
+				require.NoError(t, dec.Decode(&second))
+				assert.Equal(t, httpapi.NewPriceView(outputPrices()[0]), first)
+				assert.Nil(t, second.Value, "no value is null")
+				assert.True(t, second.Partial)
+			},
+		},
+		{
+			format: config.FormatCSV,
+			check: func(t *testing.T, out string) {
+				rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+				require.NoError(t, err)
+				require.Len(t, rows, 3, "header is written once")
+				assert.Equal(t, []string{"time", "ticker", "value", "status", "period_start", "period_end", "sources", "partial",
+					"open", "high", "low", "close", "ticks", "volume", "impact_bid", "impact_ask", "group"}, rows[0])
+				assert.Equal(t, []string{"2020-01-07T14:00:00Z", "BTC_USD", "1.500", "ok", "2020-01-07T13:59:55Z",
+					"2020-01-07T14:00:00Z", "2", "false", "1", "2", "1", "1.500", "3", "10", "", "", ""}, rows[1])
+				assert.Equal(t, []string{"2020-01-07T14:00:00Z", "ETH_USD", "", "no_value", "2020-01-07T13:59:55Z",
+					"2020-01-07T14:00:00Z", "0", "true", "", "", "", "", "", "", "", "", ""}, rows[2])
+			},
+		},
+	}
+	for _, tst := range tsts {
+		t.Run(tst.format, func(t *testing.T) {
+			tst.check(t, writeAll(t, tst.format))
+		})
+	}
+
+	_, err := newPriceWriter("xml", &bytes.Buffer{})
+	assert.Error(t, err)
+}
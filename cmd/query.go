@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/store"
+)
+
+func queryCommand(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	dir := fs.String("store", "", "store directory, required")
+	ticker := fs.String("ticker", string(pkg.BTCUSDTicker), "ticker")
+	at := fs.String("at", "", "print the value published at the time (RFC 3339)")
+	from := fs.String("from", "", "print values published since the time (RFC 3339)")
+	to := fs.String("to", "", "print values published until the time (RFC 3339), now if empty")
+	if err := fs.Parse(args); err != nil {
+		return usageError{err}
+	}
+	if fs.NArg() > 0 {
+		return usageError{errUnexpectedArgs(fs.Args())}
+	}
+	if *dir == "" {
+		return usageError{fmt.Errorf("-store is required")}
+	}
+	if (*at == "") == (*from == "") {
+		return usageError{fmt.Errorf("either -at or -from is required")}
+	}
+
+	s, err := store.Open(*dir, store.DefaultSegmentSize)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	enc := json.NewEncoder(os.Stdout)
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return usageError{fmt.Errorf("-at: %w", err)}
+		}
+		record, found, err := s.At(pkg.Ticker(*ticker), t)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("no value of %s at %s", *ticker, *at)
+		}
+		return enc.Encode(record)
+	}
+
+	fromTime, err := time.Parse(time.RFC3339, *from)
+	if err != nil {
+		return usageError{fmt.Errorf("-from: %w", err)}
+	}
+	toTime := time.Now()
+	if *to != "" {
+		if toTime, err = time.Parse(time.RFC3339, *to); err != nil {
+			return usageError{fmt.Errorf("-to: %w", err)}
+		}
+	}
+	records, err := s.Query(pkg.Ticker(*ticker), fromTime, toTime)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+)
+
+func recordCommand(args []string) error {
+	flags := newPipelineFlags("record")
+	out := flags.fs.String("out", "", "file to write prices to, required")
+	duration := flags.fs.Duration("duration", 0, "stop recording after the duration, runs until interrupted if 0")
+	if err := flags.parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return usageError{fmt.Errorf("-out is required")}
+	}
+	cfg, err := flags.load()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	recorder := pkg.NewPriceRecorder(f)
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	if *duration > 0 {
+		go func() {
+			select {
+			case <-time.After(*duration):
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	buildSources := configSources(cfg)
+	wg := &sync.WaitGroup{}
+	errCh := make(chan error, len(cfg.Tickers))
+	for _, ticker := range cfg.Tickers {
+		sources, err := buildSources()
+		if err != nil {
+			return err
+		}
+		output := pkg.NewMultiplexor().WithTicker(ticker).SubscribeSources(ctx, sources)
+		wg.Add(1)
+		go func(ticker pkg.Ticker) {
+			defer wg.Done()
+			for price := range output {
+				// sources may omit ticker of the subscribed stream, replay matches records by ticker
+				if price.Ticker == "" {
+					price.Ticker = ticker
+				}
+				if err := recorder.Record(price); err != nil {
+					errCh <- err
+					cancel()
+					return
+				}
+			}
+		}(ticker)
+	}
+	wg.Wait()
+	close(errCh)
+	if err := <-errCh; err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dshipenok/tickers/pkg"
+)
+
+func replayCommand(args []string) error {
+	flags := newPipelineFlags("replay")
+	in := flags.fs.String("in", "", "file written by record command, required")
+	speed := flags.fs.Float64("speed", 1, "replay speed factor, 2 is twice faster than recorded")
+	if err := flags.parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		return usageError{fmt.Errorf("-in is required")}
+	}
+	if *speed <= 0 {
+		return usageError{fmt.Errorf("-speed must be positive")}
+	}
+	cfg, err := flags.load()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	records, err := pkg.ReadPriceRecords(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *in, err)
+	}
+
+	// tickers come from config, so replay could be limited to some of the recorded ones
+	stream := pkg.NewReplayStream(records, *speed)
+	ctx, cancel := interruptContext()
+	defer cancel()
+	return runPipeline(ctx, cfg, func() ([]pkg.NamedSource, error) {
+		return []pkg.NamedSource{{Name: "replay", Subscriber: stream}}, nil
+	})
+}
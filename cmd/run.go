@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/config"
+	"github.com/dshipenok/tickers/pkg/httpapi"
+	"github.com/dshipenok/tickers/pkg/metrics"
+)
+
+// sourcesBuilder creates fresh sources for each ticker
+type sourcesBuilder func() ([]pkg.NamedSource, error)
+
+func runCommand(args []string) error {
+	flags := newPipelineFlags("run")
+	if err := flags.parse(args); err != nil {
+		return err
+	}
+	cfg, err := flags.load()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	return runPipeline(ctx, cfg, configSources(cfg))
+}
+
+func validateConfigCommand(args []string) error {
+	flags := newPipelineFlags("validate-config")
+	if err := flags.parse(args); err != nil {
+		return err
+	}
+	if flags.config == "" {
+		return usageError{fmt.Errorf("-config is required")}
+	}
+	if _, err := flags.load(); err != nil {
+		return err
+	}
+	fmt.Println("config is valid")
+	return nil
+}
+
+func configSources(cfg *config.Config) sourcesBuilder {
+	return func() ([]pkg.NamedSource, error) {
+		sources := make([]pkg.NamedSource, 0, len(cfg.Sources))
+		for _, src := range cfg.Sources {
+			api, err := src.Build()
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, pkg.NamedSource{Name: src.Name, Subscriber: api})
+		}
+		return sources, nil
+	}
+}
+
+// runPipeline runs fair price pipeline per ticker until ctx is done or all sources are gone.
+// On shutdown last partial periods are flushed and all goroutines are waited for.
+func runPipeline(ctx context.Context, cfg *config.Config, buildSources sourcesBuilder) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// sources and API outlive ctx, they are stopped only after the last prices are written
+	sourcesCtx, stopSources := context.WithCancel(context.Background())
+	defer stopSources()
+
+	type pipeline struct {
+		ticker    pkg.Ticker
+		sources   []pkg.NamedSource
+		collector pkg.IFairPriceCollector
+		empty     pkg.EmptyPeriodPolicy
+		failover  *pkg.FailoverPolicy
+	}
+	pipelines := make([]pipeline, 0, len(cfg.Tickers))
+	for _, ticker := range cfg.Tickers {
+		sources, err := buildSources()
+		if err != nil {
+			return err
+		}
+		c, err := cfg.Collector.Build()
+		if err != nil {
+			return err
+		}
+		empty, err := cfg.EmptyPeriod.Build()
+		if err != nil {
+			return err
+		}
+		pl := pipeline{ticker: ticker, sources: sources, collector: c, empty: empty}
+		if cfg.Failover != nil {
+			failover, err := cfg.Failover.Build(cfg.Collector)
+			if err != nil {
+				return err
+			}
+			pl.failover = &failover
+		}
+		pipelines = append(pipelines, pl)
+	}
+
+	writers, closeOutputs, err := openOutputs(cfg.Outputs)
+	if err != nil {
+		return err
+	}
+
+	registry := metrics.NewRegistry()
+	pipelineMetrics := pkg.NewMetrics(registry)
+
+	server := httpapi.NewServer(cfg.API.HistorySize, cfg.StaleAfter(), time.Now)
+	hub := httpapi.NewHub(httpapi.DefaultClientBufferSize)
+	server.Handle("/stream/sse", http.HandlerFunc(hub.ServeSSE))
+	server.Handle("/stream/ws", http.HandlerFunc(hub.ServeWebSocket))
+	server.Handle("/metrics", registry)
+	apiErrCh := make(chan error, 1)
+	if cfg.API.Listen != "" {
+		go func() {
+			err := server.ListenAndServe(sourcesCtx, cfg.API.Listen)
+			if err != nil {
+				err = fmt.Errorf("api server: %w", err)
+				cancel()
+			}
+			apiErrCh <- err
+		}()
+	} else {
+		apiErrCh <- nil
+	}
+
+	// fair prices of rate tickers are used to convert prices of other quote assets
+	converter := cfg.Conversion.Build(time.Now)
+	// fair prices pass through cross rates which add synthetic tickers
+	rates := make([]pkg.CrossRate, 0, len(cfg.CrossRates))
+	for _, rate := range cfg.CrossRates {
+		rates = append(rates, rate.Build())
+	}
+	// every ticker publishes once per period with non-blocking send, so each of them has a slot in the buffer
+	fairCh, crossDone := make(chan pkg.FairPriceResult, len(pipelines)), make(chan struct{})
+	outputCh := make(chan pkg.FairPriceResult, len(pipelines)+len(rates))
+	go func() {
+		defer close(crossDone)
+		pkg.NewCrossRates(rates, time.Now).Start(fairCh, outputCh)
+	}()
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+		for p := range outputCh {
+			if converter != nil {
+				converter.Update(p)
+			}
+			server.Update(p)
+			hub.Publish(p)
+			for _, w := range writers {
+				if err := w.Write(p); err != nil {
+					fmt.Fprintln(os.Stderr, "output:", err)
+				}
+			}
+		}
+	}()
+
+	wg := &sync.WaitGroup{}
+	multiplexors := make([]*pkg.Multiplexor, 0, len(pipelines))
+	for _, pl := range pipelines {
+		m := pkg.NewMultiplexor().
+			WithTicker(pl.ticker).
+			WithQuoteEquivalence(cfg.Equivalence()).
+			WithConverter(converter).
+			WithMetrics(pipelineMetrics)
+		if cfg.Dedup.Window > 0 {
+			m.WithDedup(cfg.Dedup.Window, cfg.Dedup.MaxEntries)
+		}
+		if cfg.Sequence.ResubscribeOnGap {
+			m.WithResubscribeOnGap()
+		}
+		if cfg.Breaker.Failures > 0 {
+			m.WithCircuitBreaker(cfg.Breaker.Build())
+		}
+		if rl := cfg.RateLimit; rl != nil {
+			m.WithRateLimit(pkg.RateLimitOptions{Interval: rl.Interval, Coalesce: rl.Coalesce, QueueSize: rl.QueueSize})
+		}
+		multiplexors = append(multiplexors, m)
+		go logSourceEvents(pl.ticker, m.Events())
+		output := m.SubscribeSources(sourcesCtx, pl.sources)
+		p := pkg.NewFairPrice(pl.collector, time.Now).
+			WithTicker(pl.ticker).
+			WithQuorum(cfg.Quorum).
+			WithMetrics(pipelineMetrics).
+			WithEmptyPeriodPolicy(pl.empty).
+			WithFinalFlush()
+		if pl.failover != nil {
+			p.WithFailover(*pl.failover)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Start(ctx, output, cfg.Period, fairCh)
+		}()
+	}
+
+	// shutdown order: fair prices are flushed, outputs are written, then sources and API are stopped
+	wg.Wait()
+	close(fairCh)
+	<-crossDone
+	close(outputCh)
+	<-printed
+	stopSources()
+	for _, m := range multiplexors {
+		m.Wait()
+	}
+	apiErr := <-apiErrCh
+	if err := closeOutputs(); err != nil {
+		return err
+	}
+	return apiErr
+}
+
+// logSourceEvents reports source problems to stderr
+func logSourceEvents(ticker pkg.Ticker, events <-chan pkg.SourceEvent) {
+	for event := range events {
+		switch event.Type {
+		case pkg.SourceDisconnected:
+			if errors.Is(event.Err, context.Canceled) {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%s: source %s disconnected: %v\n", ticker, event.Source, event.Err)
+		case pkg.SourceSequenceGap, pkg.SourceOutOfOrder:
+			fmt.Fprintf(os.Stderr, "%s: source %s %s: expected %d, got %d\n",
+				ticker, event.Source, event.Type, event.Expected, event.Got)
+		case pkg.SourceResubscribed:
+			fmt.Fprintf(os.Stderr, "%s: source %s resubscribed\n", ticker, event.Source)
+		case pkg.SourceBreaker:
+			if event.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: source %s breaker is %s: %v\n", ticker, event.Source, event.State, event.Err)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: source %s breaker is %s\n", ticker, event.Source, event.State)
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// BreakerState is a state of the circuit breaker of a source
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // prices are forwarded
+	BreakerOpen     BreakerState = "open"      // source is quarantined, its prices are dropped
+	BreakerHalfOpen BreakerState = "half_open" // source is probed after cooldown
+)
+
+var (
+	ErrPriceInvalid   = errors.New("price is invalid")
+	ErrPriceDeviation = errors.New("price deviates from other sources")
+)
+
+// BreakerOptions configures per-source circuit breaker
+type BreakerOptions struct {
+	Failures     int           // failures within the window which open the breaker
+	Window       time.Duration // failures are counted within the window, older prices of other sources aren't compared
+	Cooldown     time.Duration // time the source is quarantined before probing
+	Probes       int           // accepted prices in half-open state which close the breaker
+	MaxDeviation float64       // max relative deviation from the median of other sources, not checked if 0
+}
+
+// DefaultBreakerOptions are reasonable breaker options
+func DefaultBreakerOptions() BreakerOptions {
+	return BreakerOptions{
+		Failures: 5,
+		Window:   time.Minute,
+		Cooldown: 30 * time.Second,
+		Probes:   3,
+	}
+}
+
+// circuitBreaker counts failures of a single source. Parse errors, deviations and broken streams are failures.
+// Closed breaker opens when there are enough failures within the window, open one becomes half-open
+// after cooldown and it's closed after enough accepted prices or opened again on the first failure.
+// It isn't safe for concurrent use.
+type circuitBreaker struct {
+	opts      BreakerOptions
+	state     BreakerState
+	failures  []time.Time // failures within the window in closed state
+	openedAt  time.Time
+	successes int // accepted prices in half-open state
+}
+
+func newCircuitBreaker(opts BreakerOptions) *circuitBreaker {
+	return &circuitBreaker{
+		opts:  opts,
+		state: BreakerClosed,
+	}
+}
+
+// allow reports whether prices of the source are accepted, open breaker becomes half-open after cooldown
+func (b *circuitBreaker) allow(now time.Time) bool {
+	if b.state == BreakerOpen && b.wait(now) == 0 {
+		b.state = BreakerHalfOpen
+		b.successes = 0
+	}
+	return b.state != BreakerOpen
+}
+
+// wait returns time left until the open breaker becomes half-open
+func (b *circuitBreaker) wait(now time.Time) time.Duration {
+	if b.state != BreakerOpen {
+		return 0
+	}
+	if left := b.openedAt.Add(b.opts.Cooldown).Sub(now); left > 0 {
+		return left
+	}
+	return 0
+}
+
+func (b *circuitBreaker) failure(now time.Time) {
+	switch b.state {
+	case BreakerHalfOpen:
+		b.open(now)
+	case BreakerClosed:
+		n := 0
+		for n < len(b.failures) && now.Sub(b.failures[n]) > b.opts.Window {
+			n++
+		}
+		b.failures = append(b.failures[n:], now)
+		if len(b.failures) >= b.opts.Failures {
+			b.open(now)
+		}
+	}
+}
+
+func (b *circuitBreaker) success() {
+	if b.state != BreakerHalfOpen {
+		return
+	}
+	b.successes++
+	if b.successes >= b.opts.Probes {
+		b.state = BreakerClosed
+	}
+}
+
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = BreakerOpen
+	b.openedAt = now
+	b.failures = nil
+}
+
+// breakerReference is the latest accepted price of a source
+type breakerReference struct {
+	value float64
+	time  time.Time
+}
+
+// parsePrice validates the price, it returns the value of trade price
+func parsePrice(price TickerPrice) (float64, error) {
+	if price.Book != nil {
+		if !price.Book.Valid() {
+			return 0, fmt.Errorf("%w: empty or crossed book", ErrPriceInvalid)
+		}
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(price.Price, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%w: %q", ErrPriceInvalid, price.Price)
+	}
+	return value, nil
+}
+
+// checkDeviation compares the value with the median of fresh prices of other sources
+func checkDeviation(name string, value float64, references map[string]breakerReference, opts BreakerOptions, now time.Time) error {
+	if opts.MaxDeviation <= 0 {
+		return nil
+	}
+	values := make([]float64, 0, len(references))
+	for source, ref := range references {
+		if source != name && now.Sub(ref.time) <= opts.Window {
+			values = append(values, ref.value)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	median := values[len(values)/2]
+	if len(values)%2 == 0 {
+		median = (values[len(values)/2-1] + median) / 2
+	}
+	if median != 0 && math.Abs(value-median)/math.Abs(median) > opts.MaxDeviation {
+		return fmt.Errorf("%w: %v from median %v", ErrPriceDeviation, value, median)
+	}
+	return nil
+}
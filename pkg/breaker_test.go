@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CircuitBreaker_States(t *testing.T) {
+	tn := time.Date(2022, 3, 1, 14, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker(BreakerOptions{Failures: 2, Window: time.Minute, Cooldown: 30 * time.Second, Probes: 2})
+
+	b.failure(tn)
+	b.failure(tn.Add(2 * time.Minute))
+	assert.Equal(t, BreakerClosed, b.state, "the first failure is out of the window")
+
+	b.failure(tn.Add(2*time.Minute + time.Second))
+	assert.Equal(t, BreakerOpen, b.state)
+	assert.False(t, b.allow(tn.Add(2*time.Minute+10*time.Second)))
+	assert.Equal(t, 21*time.Second, b.wait(tn.Add(2*time.Minute+10*time.Second)))
+
+	assert.True(t, b.allow(tn.Add(3*time.Minute)))
+	assert.Equal(t, BreakerHalfOpen, b.state)
+	b.failure(tn.Add(3 * time.Minute))
+	assert.Equal(t, BreakerOpen, b.state, "failure of a probe opens the breaker again")
+
+	assert.True(t, b.allow(tn.Add(4*time.Minute)))
+	b.success()
+	assert.Equal(t, BreakerHalfOpen, b.state)
+	b.success()
+	assert.Equal(t, BreakerClosed, b.state)
+}
+
+func Test_CheckDeviation(t *testing.T) {
+	tn := time.Now()
+	opts := BreakerOptions{Window: time.Minute, MaxDeviation: 0.1}
+	references := map[string]breakerReference{
+		"a":   {value: 100, time: tn},
+		"b":   {value: 102, time: tn},
+		"c":   {value: 500, time: tn.Add(-time.Hour)},
+		"own": {value: 1, time: tn},
+	}
+
+	assert.NoError(t, checkDeviation("own", 110, references, opts, tn))
+	assert.ErrorIs(t, checkDeviation("own", 120, references, opts, tn), ErrPriceDeviation)
+	assert.NoError(t, checkDeviation("own", 120, references, BreakerOptions{Window: time.Minute}, tn), "disabled")
+	assert.NoError(t, checkDeviation("a", 1000, map[string]breakerReference{"a": {value: 1, time: tn}}, opts, tn),
+		"nothing to compare with")
+}
+
+func Test_ParsePrice(t *testing.T) {
+	value, err := parsePrice(TickerPrice{Price: "12.5"})
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, value)
+	_, err = parsePrice(TickerPrice{Price: "NaN"})
+	assert.ErrorIs(t, err, ErrPriceInvalid)
+	_, err = parsePrice(TickerPrice{Price: ""})
+	assert.ErrorIs(t, err, ErrPriceInvalid)
+}
@@ -36,7 +36,7 @@ func (c *Average) GetFairPriceAndReset() string {
 	defer c.m.Unlock()
 	count := len(c.prices)
 	if count == 0 {
-		return "no value"
+		return NoValue
 	}
 	sum := 0.
 	for _, v := range c.prices {
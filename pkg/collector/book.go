@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Level is a price level of order book
+type Level struct {
+	Price float64 `json:"price"`
+	Size  float64 `json:"size"`
+}
+
+// Book is a snapshot of the source's order book: best bid/ask and optionally more top levels
+type Book struct {
+	Source string    `json:"-"`
+	Bids   []Level   `json:"bids"` // best first
+	Asks   []Level   `json:"asks"` // best first
+	Time   time.Time `json:"-"`
+}
+
+// Valid reports whether both sides are present and the book is not crossed
+func (b Book) Valid() bool {
+	return len(b.Bids) > 0 && len(b.Asks) > 0 && b.Bids[0].Price < b.Asks[0].Price
+}
+
+// IBookPricer calculates a price of valid book
+type IBookPricer interface {
+	Price(book Book) (float64, bool)
+}
+
+// BookPricerFunc adapts function to IBookPricer
+type BookPricerFunc func(book Book) (float64, bool)
+
+func (f BookPricerFunc) Price(book Book) (float64, bool) {
+	return f(book)
+}
+
+// MidPrice is the middle between best bid and best ask
+func MidPrice() BookPricerFunc {
+	return func(book Book) (float64, bool) {
+		return (book.Bids[0].Price + book.Asks[0].Price) / 2, true
+	}
+}
+
+// MicroPrice is the mid weighted by sizes of the opposite side, so it moves towards the thinner side
+func MicroPrice() BookPricerFunc {
+	return func(book Book) (float64, bool) {
+		bid, ask := book.Bids[0], book.Asks[0]
+		if bid.Size+ask.Size <= 0 {
+			return (bid.Price + ask.Price) / 2, true
+		}
+		return (bid.Price*ask.Size + ask.Price*bid.Size) / (bid.Size + ask.Size), true
+	}
+}
+
+// DepthWeightedMid is the middle between size-weighted average prices of top levels of each side
+func DepthWeightedMid(levels int) BookPricerFunc {
+	return func(book Book) (float64, bool) {
+		bid, ok := depthPrice(book.Bids, levels)
+		if !ok {
+			return 0, false
+		}
+		ask, ok := depthPrice(book.Asks, levels)
+		if !ok {
+			return 0, false
+		}
+		return (bid + ask) / 2, true
+	}
+}
+
+func depthPrice(side []Level, levels int) (float64, bool) {
+	if levels > 0 && len(side) > levels {
+		side = side[:levels]
+	}
+	sum, size := 0., 0.
+	for _, level := range side {
+		sum += level.Price * level.Size
+		size += level.Size
+	}
+	if size <= 0 {
+		return 0, false
+	}
+	return sum / size, true
+}
+
+// bookSnapshots keeps the latest by event time valid book of each source
+type bookSnapshots struct {
+	m     sync.Mutex
+	books map[string]Book
+}
+
+// collect returns false if the book is rejected as invalid, an older book of the source is skipped silently
+func (s *bookSnapshots) collect(book Book) bool {
+	if !book.Valid() {
+		return false
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if prev, found := s.books[book.Source]; found && book.Time.Before(prev.Time) {
+		return true
+	}
+	if s.books == nil {
+		s.books = map[string]Book{}
+	}
+	s.books[book.Source] = book
+	return true
+}
+
+// takeAndReset returns books sorted by source, so results don't depend on map order
+func (s *bookSnapshots) takeAndReset() []Book {
+	s.m.Lock()
+	books := make([]Book, 0, len(s.books))
+	for _, book := range s.books {
+		books = append(books, book)
+	}
+	s.books = nil
+	s.m.Unlock()
+	sort.Slice(books, func(i, j int) bool { return books[i].Source < books[j].Source })
+	return books
+}
+
+// priceBooks aggregates prices of books
+func priceBooks(books []Book, pricer IBookPricer, aggregator IAggregator) (float64, bool) {
+	samples := make([]Sample, 0, len(books))
+	for _, book := range books {
+		if price, ok := pricer.Price(book); ok {
+			samples = append(samples, Sample{Source: book.Source, Price: price, Time: book.Time})
+		}
+	}
+	if len(samples) == 0 {
+		return 0, false
+	}
+	return aggregator.Aggregate(samples)
+}
+
+// BookPrice keeps the latest by event time book of each source within the period,
+// prices them and aggregates these prices. Trades are ignored.
+type BookPrice struct {
+	snapshots bookSnapshots
+
+	pricer     IBookPricer
+	aggregator IAggregator
+	precision  int
+}
+
+// NewBookPrice constructor
+func NewBookPrice(precision int, pricer IBookPricer, aggregator IAggregator) *BookPrice {
+	return &BookPrice{
+		pricer:     pricer,
+		aggregator: aggregator,
+		precision:  precision,
+	}
+}
+
+// NewMid constructor of collector producing median of sources' mid-prices
+func NewMid(precision int) *BookPrice {
+	return NewBookPrice(precision, MidPrice(), Median())
+}
+
+// NewMicroPrice constructor of collector producing median of sources' micro-prices
+func NewMicroPrice(precision int) *BookPrice {
+	return NewBookPrice(precision, MicroPrice(), Median())
+}
+
+// NewDepthWeightedMid constructor of collector producing median of sources' depth-weighted mid-prices
+func NewDepthWeightedMid(precision, levels int) *BookPrice {
+	return NewBookPrice(precision, DepthWeightedMid(levels), Median())
+}
+
+func (c *BookPrice) Collect(string, time.Time) error {
+	return ErrNotCollected
+}
+
+func (c *BookPrice) CollectBook(book Book) error {
+	if !c.snapshots.collect(book) {
+		return ErrNotCollected
+	}
+	return nil
+}
+
+func (c *BookPrice) GetFairPriceAndReset() string {
+	value, ok := priceBooks(c.snapshots.takeAndReset(), c.pricer, c.aggregator)
+	if !ok {
+		return NoValue
+	}
+	return strconv.FormatFloat(value, 'f', c.precision, 64)
+}
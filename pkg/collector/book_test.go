@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBook(source string, t time.Time) Book {
+	return Book{
+		Source: source,
+		Bids:   []Level{{Price: 99, Size: 3}, {Price: 98, Size: 1}},
+		Asks:   []Level{{Price: 101, Size: 1}, {Price: 104, Size: 3}},
+		Time:   t,
+	}
+}
+
+func Test_BookPricers(t *testing.T) {
+	book := testBook("a", tn)
+	testCases := []struct {
+		name     string
+		pricer   IBookPricer
+		expected float64
+	}{
+		{name: "mid", pricer: MidPrice(), expected: 100},
+		{name: "micro", pricer: MicroPrice(), expected: 100.5},
+		{name: "depth 1", pricer: DepthWeightedMid(1), expected: 100},
+		{name: "depth 2", pricer: DepthWeightedMid(2), expected: (98.75 + 103.25) / 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, ok := tc.pricer.Price(book)
+			require.True(t, ok)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func Test_BookPrice(t *testing.T) {
+	c := NewMid(1)
+	assert.Equal(t, NoValue, c.GetFairPriceAndReset())
+
+	assert.ErrorIs(t, c.Collect("1", tn), ErrNotCollected, "trades are ignored")
+	require.NoError(t, c.CollectBook(testBook("a", tn.Add(time.Second))))
+	require.NoError(t, c.CollectBook(Book{Source: "a", Bids: []Level{{Price: 1}}, Asks: []Level{{Price: 2}}, Time: tn})) // older
+	assert.ErrorIs(t, c.CollectBook(Book{Source: "b", Bids: []Level{{Price: 2}}, Asks: []Level{{Price: 1}}, Time: tn}), ErrNotCollected, "crossed")
+	assert.ErrorIs(t, c.CollectBook(Book{Source: "c", Bids: []Level{{Price: 2}}, Time: tn}), ErrNotCollected, "one-sided")
+	assert.Equal(t, "100.0", c.GetFairPriceAndReset())
+	assert.Equal(t, NoValue, c.GetFairPriceAndReset())
+}
+
+func Test_ImpactPrice(t *testing.T) {
+	c := NewImpactPrice(300, 2)
+	assert.Equal(t, NoValue, c.GetFairPriceAndReset())
+
+	// sell 300: 297 at 99, remaining 3 at 98; buy 300: 101 at 101, remaining 199 at 104
+	require.NoError(t, c.CollectBook(testBook("a", tn)))
+	require.NoError(t, c.CollectBook(Book{ // too thin for the notional
+		Source: "b",
+		Bids:   []Level{{Price: 50, Size: 1}},
+		Asks:   []Level{{Price: 150, Size: 1}},
+		Time:   tn,
+	}))
+	assert.ErrorIs(t, c.CollectBook(Book{Source: "c", Bids: []Level{{Price: 13, Size: 1}}, Asks: []Level{{Price: 12, Size: 1}}, Time: tn}),
+		ErrNotCollected, "crossed")
+	impact, ok := c.GetImpactAndReset()
+	require.True(t, ok)
+	bid, ask := 300/(3+3./98), 300/(1+199./104)
+	assert.Equal(t, Impact{
+		Notional: "300",
+		Bid:      fmt.Sprintf("%.2f", bid),
+		Ask:      fmt.Sprintf("%.2f", ask),
+		Mid:      fmt.Sprintf("%.2f", (bid+ask)/2),
+	}, impact)
+
+	_, ok = c.GetImpactAndReset()
+	assert.False(t, ok, "books are reset")
+}
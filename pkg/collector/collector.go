@@ -0,0 +1,21 @@
+package collector
+
+import (
+	"errors"
+	"time"
+)
+
+// NoValue is returned by collectors when there were no prices within the period
+const NoValue = "no value"
+
+// ErrNotCollected is returned for events the collector ignores, e.g. trades by book collectors,
+// so they aren't counted as contributions of their sources
+var ErrNotCollected = errors.New("event is not collected")
+
+// Trade is a single price with details available from the source
+type Trade struct {
+	Source string
+	Price  string // decimal value
+	Size   string // decimal value, empty if the source doesn't provide it
+	Time   time.Time
+}
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EMA is exponential moving average with half-life in time. Unlike other collectors its state
+// is kept across periods, so the value is defined even for periods without prices.
+//
+// Every price has weight 2^(-age/halfLife), where age is measured by event time from the latest price.
+// It makes the result independent of arrival order and averages prices with the same time equally.
+type EMA struct {
+	m sync.Mutex
+
+	halfLife  time.Duration
+	precision int
+
+	weightedSum float64
+	weight      float64
+	latestTime  time.Time
+}
+
+// NewEMA constructor
+func NewEMA(halfLife time.Duration, precision int) *EMA {
+	return &EMA{
+		halfLife:  halfLife,
+		precision: precision,
+	}
+}
+
+func (c *EMA) Collect(price string, t time.Time) error {
+	f, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return err
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.weight == 0 {
+		c.weightedSum, c.weight, c.latestTime = f, 1, t
+		return nil
+	}
+	if t.After(c.latestTime) {
+		decay := c.decay(t.Sub(c.latestTime))
+		c.weightedSum *= decay
+		c.weight *= decay
+		c.latestTime = t
+		c.weightedSum += f
+		c.weight++
+		return nil
+	}
+	// price arrived late, it's older than the latest one
+	w := c.decay(c.latestTime.Sub(t))
+	c.weightedSum += f * w
+	c.weight += w
+	return nil
+}
+
+// GetFairPriceAndReset returns current average, state is not reset deliberately
+func (c *EMA) GetFairPriceAndReset() string {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.weight == 0 {
+		return NoValue
+	}
+	return strconv.FormatFloat(c.weightedSum/c.weight, 'f', c.precision, 64)
+}
+
+func (c *EMA) decay(age time.Duration) float64 {
+	if c.halfLife <= 0 {
+		return 0 // no memory, the latest price wins
+	}
+	return math.Exp2(-float64(age) / float64(c.halfLife))
+}
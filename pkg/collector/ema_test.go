@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tn = time.Date(2020, 1, 1, 10, 5, 0, 0, time.UTC)
+
+func Test_EMA(t *testing.T) {
+	c := NewEMA(time.Minute, 3)
+	assert.Equal(t, NoValue, c.GetFairPriceAndReset())
+
+	require.NoError(t, c.Collect("1.0", tn))
+	require.NoError(t, c.Collect("3.0", tn)) // same time, equal weights
+	assert.Equal(t, "2.000", c.GetFairPriceAndReset())
+	assert.Equal(t, "2.000", c.GetFairPriceAndReset(), "state is kept across periods")
+
+	require.NoError(t, c.Collect("5.0", tn.Add(time.Minute))) // old prices have half weight: (0.5*1 + 0.5*3 + 5) / 2
+	assert.Equal(t, "3.500", c.GetFairPriceAndReset())
+
+	assert.Error(t, c.Collect("x", tn))
+}
+
+func Test_EMA_ArrivalOrderDoesNotMatter(t *testing.T) {
+	c1, c2 := NewEMA(time.Minute, 6), NewEMA(time.Minute, 6)
+	require.NoError(t, c1.Collect("1.0", tn))
+	require.NoError(t, c1.Collect("2.0", tn.Add(30*time.Second)))
+	require.NoError(t, c1.Collect("4.0", tn.Add(90*time.Second)))
+
+	require.NoError(t, c2.Collect("4.0", tn.Add(90*time.Second)))
+	require.NoError(t, c2.Collect("1.0", tn))
+	require.NoError(t, c2.Collect("2.0", tn.Add(30*time.Second)))
+
+	assert.Equal(t, c1.GetFairPriceAndReset(), c2.GetFairPriceAndReset())
+}
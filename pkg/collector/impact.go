@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"strconv"
+	"time"
+)
+
+// Impact is impact prices of the period: average fill prices of market orders of the notional
+type Impact struct {
+	Notional string `json:"notional"`
+	Bid      string `json:"bid"` // selling into bids
+	Ask      string `json:"ask"` // buying from asks
+	Mid      string `json:"mid"`
+}
+
+// ImpactBid is the average fill price of a market sell order of the notional, false if the book is too thin
+func ImpactBid(notional float64) BookPricerFunc {
+	return func(book Book) (float64, bool) {
+		return fillPrice(book.Bids, notional)
+	}
+}
+
+// ImpactAsk is the average fill price of a market buy order of the notional, false if the book is too thin
+func ImpactAsk(notional float64) BookPricerFunc {
+	return func(book Book) (float64, bool) {
+		return fillPrice(book.Asks, notional)
+	}
+}
+
+// fillPrice walks levels until the notional is filled, the result is notional divided by filled quantity
+func fillPrice(side []Level, notional float64) (float64, bool) {
+	if notional <= 0 {
+		return 0, false
+	}
+	remaining, quantity := notional, 0.
+	for _, level := range side {
+		if level.Price <= 0 || level.Size <= 0 {
+			continue
+		}
+		levelNotional := level.Price * level.Size
+		if levelNotional >= remaining {
+			quantity += remaining / level.Price
+			return notional / quantity, true
+		}
+		remaining -= levelNotional
+		quantity += level.Size
+	}
+	return 0, false
+}
+
+// ImpactPrice keeps the latest by event time book of each source within the period and produces
+// impact bid and ask as medians of sources' impact prices, impact mid is the middle between them.
+// Books not deep enough for the notional are ignored on that side. Trades are ignored.
+type ImpactPrice struct {
+	snapshots bookSnapshots
+
+	notional  float64
+	precision int
+}
+
+// NewImpactPrice constructor
+func NewImpactPrice(notional float64, precision int) *ImpactPrice {
+	return &ImpactPrice{
+		notional:  notional,
+		precision: precision,
+	}
+}
+
+func (c *ImpactPrice) Collect(string, time.Time) error {
+	return ErrNotCollected
+}
+
+func (c *ImpactPrice) CollectBook(book Book) error {
+	if !c.snapshots.collect(book) {
+		return ErrNotCollected
+	}
+	return nil
+}
+
+// GetImpactAndReset returns false if impact bid or ask is unknown
+func (c *ImpactPrice) GetImpactAndReset() (Impact, bool) {
+	books := c.snapshots.takeAndReset()
+	bid, ok := priceBooks(books, ImpactBid(c.notional), Median())
+	if !ok {
+		return Impact{}, false
+	}
+	ask, ok := priceBooks(books, ImpactAsk(c.notional), Median())
+	if !ok {
+		return Impact{}, false
+	}
+	return Impact{
+		Notional: strconv.FormatFloat(c.notional, 'f', -1, 64),
+		Bid:      c.format(bid),
+		Ask:      c.format(ask),
+		Mid:      c.format((bid + ask) / 2),
+	}, true
+}
+
+// GetFairPriceAndReset returns impact mid
+func (c *ImpactPrice) GetFairPriceAndReset() string {
+	impact, ok := c.GetImpactAndReset()
+	if !ok {
+		return NoValue
+	}
+	return impact.Mid
+}
+
+func (c *ImpactPrice) format(value float64) string {
+	return strconv.FormatFloat(value, 'f', c.precision, 64)
+}
@@ -41,7 +41,7 @@ func (c *Latest) Collect(price string, t time.Time) error {
 
 func (c *Latest) GetFairPriceAndReset() string {
 	if !c.hasPrice {
-		return "no value"
+		return NoValue
 	}
 	c.hasPrice = false
 	return strconv.FormatFloat(c.latestPrice, 'f', c.precision, 64)
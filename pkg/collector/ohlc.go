@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Candle is OHLC summary of a period. Prices are ordered by event time, not by arrival.
+type Candle struct {
+	Open      string    `json:"open"`
+	High      string    `json:"high"`
+	Low       string    `json:"low"`
+	Close     string    `json:"close"`
+	Ticks     int       `json:"ticks"`
+	Volume    string    `json:"volume,omitempty"` // empty if no trade had size
+	OpenTime  time.Time `json:"open_time"`
+	CloseTime time.Time `json:"close_time"`
+}
+
+type OHLC struct {
+	m sync.Mutex
+
+	ticks     int
+	open      float64
+	high      float64
+	low       float64
+	close     float64
+	openTime  time.Time
+	closeTime time.Time
+	volume    float64
+	hasVolume bool
+	precision int
+}
+
+// NewOHLC constructor
+func NewOHLC(precision int) *OHLC {
+	return &OHLC{
+		precision: precision,
+	}
+}
+
+func (c *OHLC) Collect(price string, t time.Time) error {
+	return c.CollectTrade(Trade{Price: price, Time: t})
+}
+
+func (c *OHLC) CollectTrade(trade Trade) error {
+	f, err := strconv.ParseFloat(trade.Price, 64)
+	if err != nil {
+		return err
+	}
+	size, hasSize := 0., trade.Size != ""
+	if hasSize {
+		if size, err = strconv.ParseFloat(trade.Size, 64); err != nil {
+			return err
+		}
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.ticks == 0 {
+		c.open, c.high, c.low, c.close = f, f, f, f
+		c.openTime, c.closeTime = trade.Time, trade.Time
+	}
+	c.ticks++
+	if f > c.high {
+		c.high = f
+	}
+	if f < c.low {
+		c.low = f
+	}
+	if trade.Time.Before(c.openTime) {
+		c.open, c.openTime = f, trade.Time
+	}
+	if !trade.Time.Before(c.closeTime) {
+		c.close, c.closeTime = f, trade.Time
+	}
+	if hasSize {
+		c.volume += size
+		c.hasVolume = true
+	}
+	return nil
+}
+
+// GetFairPriceAndReset returns close price of the period
+func (c *OHLC) GetFairPriceAndReset() string {
+	candle, ok := c.GetCandleAndReset()
+	if !ok {
+		return NoValue
+	}
+	return candle.Close
+}
+
+// GetCandleAndReset returns candle of the period, false if there were no prices
+func (c *OHLC) GetCandleAndReset() (Candle, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.ticks == 0 {
+		return Candle{}, false
+	}
+	candle := Candle{
+		Open:      c.format(c.open),
+		High:      c.format(c.high),
+		Low:       c.format(c.low),
+		Close:     c.format(c.close),
+		Ticks:     c.ticks,
+		OpenTime:  c.openTime,
+		CloseTime: c.closeTime,
+	}
+	if c.hasVolume {
+		candle.Volume = c.format(c.volume)
+	}
+	c.ticks, c.volume, c.hasVolume = 0, 0, false
+	return candle, true
+}
+
+func (c *OHLC) format(value float64) string {
+	return strconv.FormatFloat(value, 'f', c.precision, 64)
+}
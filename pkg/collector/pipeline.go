@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sample is a parsed trade passed through pipeline stages
+type Sample struct {
+	Source string
+	Price  float64
+	Size   float64 // 0 if the source doesn't provide it
+	Weight float64 // weight of the source, 0 if not configured
+	Time   time.Time
+}
+
+// IFilter is a pipeline stage which drops or transforms samples of the period
+type IFilter interface {
+	Filter(samples []Sample) []Sample
+}
+
+// IAggregator is the final pipeline stage producing fair price of the period
+type IAggregator interface {
+	// Aggregate returns false if there is no value
+	Aggregate(samples []Sample) (float64, bool)
+}
+
+// FilterFunc adapts function to IFilter
+type FilterFunc func(samples []Sample) []Sample
+
+func (f FilterFunc) Filter(samples []Sample) []Sample {
+	return f(samples)
+}
+
+// AggregatorFunc adapts function to IAggregator
+type AggregatorFunc func(samples []Sample) (float64, bool)
+
+func (f AggregatorFunc) Aggregate(samples []Sample) (float64, bool) {
+	return f(samples)
+}
+
+// Pipeline collects samples of the period and at its end passes them through filters
+// in order and then to aggregator, e.g. "deviation filter -> latest per source -> weighted median".
+type Pipeline struct {
+	m sync.Mutex
+
+	filters    []IFilter
+	aggregator IAggregator
+	precision  int
+	samples    []Sample
+}
+
+// NewPipeline constructor
+func NewPipeline(precision int, aggregator IAggregator, filters ...IFilter) *Pipeline {
+	return &Pipeline{
+		filters:    filters,
+		aggregator: aggregator,
+		precision:  precision,
+	}
+}
+
+func (c *Pipeline) Collect(price string, t time.Time) error {
+	return c.CollectTrade(Trade{Price: price, Time: t})
+}
+
+func (c *Pipeline) CollectTrade(trade Trade) error {
+	sample, err := ParseSample(trade)
+	if err != nil {
+		return err
+	}
+	c.m.Lock()
+	c.samples = append(c.samples, sample)
+	c.m.Unlock()
+	return nil
+}
+
+func (c *Pipeline) GetFairPriceAndReset() string {
+	c.m.Lock()
+	samples := c.samples
+	c.samples = nil
+	c.m.Unlock()
+
+	for _, filter := range c.filters {
+		if len(samples) == 0 {
+			break
+		}
+		samples = filter.Filter(samples)
+	}
+	if len(samples) == 0 {
+		return NoValue
+	}
+	value, ok := c.aggregator.Aggregate(samples)
+	if !ok {
+		return NoValue
+	}
+	return strconv.FormatFloat(value, 'f', c.precision, 64)
+}
+
+// ParseSample converts trade into sample
+func ParseSample(trade Trade) (Sample, error) {
+	price, err := strconv.ParseFloat(trade.Price, 64)
+	if err != nil {
+		return Sample{}, err
+	}
+	size := 0.
+	if trade.Size != "" {
+		if size, err = strconv.ParseFloat(trade.Size, 64); err != nil {
+			return Sample{}, err
+		}
+	}
+	return Sample{Source: trade.Source, Price: price, Size: size, Time: trade.Time}, nil
+}
+
+// NewMedian constructor of collector producing median price of the period
+func NewMedian(precision int) *Pipeline {
+	return NewPipeline(precision, Median())
+}
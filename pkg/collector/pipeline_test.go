@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Pipeline(t *testing.T) {
+	c := NewPipeline(2, WeightedMedian(), DeviationFilter(0.1), LatestPerSource())
+	assert.Equal(t, NoValue, c.GetFairPriceAndReset())
+
+	trades := []Trade{
+		{Source: "a", Price: "100", Size: "1", Time: tn},
+		{Source: "a", Price: "101", Size: "3", Time: tn.Add(time.Second)},
+		{Source: "b", Price: "102", Size: "1", Time: tn},
+		{Source: "c", Price: "103", Size: "1", Time: tn},
+		{Source: "d", Price: "500", Size: "10", Time: tn}, // dropped by deviation filter
+	}
+	for _, trade := range trades {
+		require.NoError(t, c.CollectTrade(trade))
+	}
+	assert.Equal(t, "101.00", c.GetFairPriceAndReset())
+	assert.Equal(t, NoValue, c.GetFairPriceAndReset(), "samples are reset")
+
+	assert.Error(t, c.CollectTrade(Trade{Price: "x"}))
+	assert.Error(t, c.CollectTrade(Trade{Price: "1", Size: "x"}))
+}
+
+func Test_Aggregators(t *testing.T) {
+	samples := []Sample{
+		{Price: 1, Size: 1, Time: tn.Add(time.Second)},
+		{Price: 2, Size: 1, Time: tn},
+		{Price: 6, Size: 4, Time: tn},
+	}
+	testCases := []struct {
+		name     string
+		expected float64
+	}{
+		{name: AggregatorMean, expected: 3},
+		{name: AggregatorMedian, expected: 2},
+		{name: AggregatorWeightedMean, expected: 4.5},
+		{name: AggregatorWeightedMedian, expected: 6},
+		{name: AggregatorLatest, expected: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			aggregator, err := AggregatorByName(tc.name)
+			require.NoError(t, err)
+			value, ok := aggregator.Aggregate(samples)
+			require.True(t, ok)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+
+	_, err := AggregatorByName("mode")
+	assert.Error(t, err)
+}
+
+func Test_FilterSpec(t *testing.T) {
+	filter, err := FilterSpec{Type: FilterTimeWindow, Window: time.Second}.Build()
+	require.NoError(t, err)
+	samples := filter.Filter([]Sample{{Price: 1, Time: tn}, {Price: 2, Time: tn.Add(2 * time.Second)}})
+	assert.Equal(t, []Sample{{Price: 2, Time: tn.Add(2 * time.Second)}}, samples)
+	assert.Empty(t, filter.Filter(nil))
+
+	_, err = FilterSpec{Type: FilterDeviation}.Build()
+	assert.Error(t, err)
+	_, err = FilterSpec{Type: "unknown"}.Build()
+	assert.Error(t, err)
+}
@@ -0,0 +1,358 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of built-in collectors
+const (
+	NameLatest       = "latest"
+	NameAverage      = "average"
+	NameMedian       = "median"
+	NameOHLC         = "ohlc"
+	NameEMA          = "ema"
+	NamePipeline     = "pipeline"
+	NameSourceLatest = "source_latest"
+	NameMid          = "mid"
+	NameMicroPrice   = "micro_price"
+	NameDepthMid     = "depth_mid"
+	NameImpact       = "impact"
+)
+
+// ICollector is implemented by every collector, it's the same as pkg.IFairPriceCollector
+type ICollector interface {
+	Collect(price string, t time.Time) error
+	GetFairPriceAndReset() string
+}
+
+// IOptions are typed options of registered collector
+type IOptions interface {
+	// Base gives access to options common for all collectors
+	Base() *Options
+	Validate() error
+}
+
+// Options are common for all collectors, typed options of other collectors embed them
+type Options struct {
+	Precision int `yaml:"precision"` // number of digits after decimal point
+}
+
+func (o *Options) Base() *Options {
+	return o
+}
+
+func (o *Options) Validate() error {
+	if o.Precision < 0 || o.Precision > 18 {
+		return fmt.Errorf("precision must be within [0, 18], got %d", o.Precision)
+	}
+	return nil
+}
+
+// EMAOptions are options of "ema" collector
+type EMAOptions struct {
+	Options  `yaml:",inline"`
+	HalfLife time.Duration `yaml:"half_life"`
+}
+
+func (o *EMAOptions) Validate() error {
+	if o.HalfLife <= 0 {
+		return fmt.Errorf("half_life must be positive duration")
+	}
+	return o.Options.Validate()
+}
+
+// PipelineOptions are options of "pipeline" collector
+type PipelineOptions struct {
+	Options    `yaml:",inline"`
+	Filters    []FilterSpec `yaml:"filters"`
+	Aggregator string       `yaml:"aggregator"`
+}
+
+func (o *PipelineOptions) Validate() error {
+	_, err := o.build()
+	return err
+}
+
+func (o *PipelineOptions) build() (*Pipeline, error) {
+	if err := o.Options.Validate(); err != nil {
+		return nil, err
+	}
+	aggregator, err := AggregatorByName(o.Aggregator)
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]IFilter, 0, len(o.Filters))
+	for i, spec := range o.Filters {
+		filter, err := spec.Build()
+		if err != nil {
+			return nil, fmt.Errorf("filters[%d]: %w", i, err)
+		}
+		filters = append(filters, filter)
+	}
+	return NewPipeline(o.Precision, aggregator, filters...), nil
+}
+
+// SourceLatestOptions are options of "source_latest" collector
+type SourceLatestOptions struct {
+	Options    `yaml:",inline"`
+	Aggregator string             `yaml:"aggregator"`
+	Weights    map[string]float64 `yaml:"weights"` // per-source weights, all sources are used equally if empty
+}
+
+func (o *SourceLatestOptions) Validate() error {
+	if _, err := AggregatorByName(o.Aggregator); err != nil {
+		return err
+	}
+	for source, weight := range o.Weights {
+		if weight < 0 {
+			return fmt.Errorf("weight of %q must not be negative", source)
+		}
+	}
+	return o.Options.Validate()
+}
+
+// BookOptions are options of order book collectors
+type BookOptions struct {
+	Options    `yaml:",inline"`
+	Aggregator string `yaml:"aggregator"` // aggregation of sources' prices
+}
+
+func (o *BookOptions) Validate() error {
+	if _, err := AggregatorByName(o.Aggregator); err != nil {
+		return err
+	}
+	return o.Options.Validate()
+}
+
+// DepthOptions are options of "depth_mid" collector
+type DepthOptions struct {
+	BookOptions `yaml:",inline"`
+	Levels      int `yaml:"levels"` // number of top levels of each side
+}
+
+func (o *DepthOptions) Validate() error {
+	if o.Levels <= 0 {
+		return fmt.Errorf("levels must be positive")
+	}
+	return o.BookOptions.Validate()
+}
+
+// ImpactOptions are options of "impact" collector
+type ImpactOptions struct {
+	Options  `yaml:",inline"`
+	Notional float64 `yaml:"notional"` // notional of market order in quote currency
+}
+
+func (o *ImpactOptions) Validate() error {
+	if o.Notional <= 0 {
+		return fmt.Errorf("notional must be positive")
+	}
+	return o.Options.Validate()
+}
+
+// Factory creates collectors of registered strategy
+type Factory struct {
+	// NewOptions returns options filled with defaults
+	NewOptions func() IOptions
+	// New creates collector, options are validated and have type returned by NewOptions
+	New func(opts IOptions) (ICollector, error)
+}
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+}{factories: map[string]Factory{}}
+
+// Register makes collector available by name, it panics if the name is already registered.
+// It's intended to be called from init functions of packages providing collectors.
+func Register(name string, factory Factory) {
+	if name == "" || factory.NewOptions == nil || factory.New == nil {
+		panic("collector: Register called with empty name or factory")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, found := registry.factories[name]; found {
+		panic(fmt.Sprintf("collector: Register called twice for %q", name))
+	}
+	registry.factories[name] = factory
+}
+
+// Names returns sorted names of registered collectors
+func Names() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewOptions returns default options of registered collector
+func NewOptions(name string) (IOptions, error) {
+	factory, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return factory.NewOptions(), nil
+}
+
+// New validates options and creates registered collector, default options are used if opts is nil
+func New(name string, opts IOptions) (ICollector, error) {
+	factory, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = factory.NewOptions()
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return factory.New(opts)
+}
+
+func lookup(name string) (Factory, error) {
+	registry.RLock()
+	factory, found := registry.factories[name]
+	registry.RUnlock()
+	if !found {
+		return Factory{}, fmt.Errorf("unknown strategy %q, expected one of %s", name, quoteNames(Names()))
+	}
+	return factory, nil
+}
+
+func defaultOptions() IOptions {
+	return &Options{Precision: 3}
+}
+
+// precisionFactory registers collector having only common options
+func precisionFactory(create func(precision int) ICollector) Factory {
+	return Factory{
+		NewOptions: defaultOptions,
+		New: func(opts IOptions) (ICollector, error) {
+			return create(opts.Base().Precision), nil
+		},
+	}
+}
+
+func defaultBookOptions() *BookOptions {
+	return &BookOptions{Options: Options{Precision: 3}, Aggregator: AggregatorMedian}
+}
+
+// bookFactory registers order book collector having only BookOptions
+func bookFactory(pricer IBookPricer) Factory {
+	return Factory{
+		NewOptions: func() IOptions { return defaultBookOptions() },
+		New: func(opts IOptions) (ICollector, error) {
+			o, ok := opts.(*BookOptions)
+			if !ok {
+				return nil, unexpectedOptions(opts)
+			}
+			aggregator, err := AggregatorByName(o.Aggregator)
+			if err != nil {
+				return nil, err
+			}
+			return NewBookPrice(o.Precision, pricer, aggregator), nil
+		},
+	}
+}
+
+func unexpectedOptions(opts IOptions) error {
+	return fmt.Errorf("unexpected options type %T", opts)
+}
+
+func init() {
+	Register(NameLatest, precisionFactory(func(precision int) ICollector { return NewLatest(precision) }))
+	Register(NameAverage, precisionFactory(func(precision int) ICollector { return NewAverage(precision) }))
+	Register(NameMedian, precisionFactory(func(precision int) ICollector { return NewMedian(precision) }))
+	Register(NameOHLC, precisionFactory(func(precision int) ICollector { return NewOHLC(precision) }))
+	Register(NameEMA, Factory{
+		NewOptions: func() IOptions {
+			return &EMAOptions{Options: Options{Precision: 3}, HalfLife: time.Minute}
+		},
+		New: func(opts IOptions) (ICollector, error) {
+			o, ok := opts.(*EMAOptions)
+			if !ok {
+				return nil, unexpectedOptions(opts)
+			}
+			return NewEMA(o.HalfLife, o.Precision), nil
+		},
+	})
+	Register(NameSourceLatest, Factory{
+		NewOptions: func() IOptions {
+			return &SourceLatestOptions{Options: Options{Precision: 3}, Aggregator: AggregatorMedian}
+		},
+		New: func(opts IOptions) (ICollector, error) {
+			o, ok := opts.(*SourceLatestOptions)
+			if !ok {
+				return nil, unexpectedOptions(opts)
+			}
+			aggregator, err := AggregatorByName(o.Aggregator)
+			if err != nil {
+				return nil, err
+			}
+			c := NewSourceLatest(o.Precision, aggregator)
+			if len(o.Weights) > 0 {
+				c.WithWeights(o.Weights)
+			}
+			return c, nil
+		},
+	})
+	Register(NameMid, bookFactory(MidPrice()))
+	Register(NameMicroPrice, bookFactory(MicroPrice()))
+	Register(NameDepthMid, Factory{
+		NewOptions: func() IOptions {
+			return &DepthOptions{BookOptions: *defaultBookOptions(), Levels: 5}
+		},
+		New: func(opts IOptions) (ICollector, error) {
+			o, ok := opts.(*DepthOptions)
+			if !ok {
+				return nil, unexpectedOptions(opts)
+			}
+			aggregator, err := AggregatorByName(o.Aggregator)
+			if err != nil {
+				return nil, err
+			}
+			return NewBookPrice(o.Precision, DepthWeightedMid(o.Levels), aggregator), nil
+		},
+	})
+	Register(NameImpact, Factory{
+		NewOptions: func() IOptions {
+			return &ImpactOptions{Options: Options{Precision: 3}, Notional: 10000}
+		},
+		New: func(opts IOptions) (ICollector, error) {
+			o, ok := opts.(*ImpactOptions)
+			if !ok {
+				return nil, unexpectedOptions(opts)
+			}
+			return NewImpactPrice(o.Notional, o.Precision), nil
+		},
+	})
+	Register(NamePipeline, Factory{
+		NewOptions: func() IOptions {
+			return &PipelineOptions{Options: Options{Precision: 3}, Aggregator: AggregatorWeightedMedian}
+		},
+		New: func(opts IOptions) (ICollector, error) {
+			o, ok := opts.(*PipelineOptions)
+			if !ok {
+				return nil, unexpectedOptions(opts)
+			}
+			return o.build()
+		},
+	})
+}
+
+func quoteNames(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, strconv.Quote(name))
+	}
+	return strings.Join(quoted, ", ")
+}
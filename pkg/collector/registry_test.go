@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Registry(t *testing.T) {
+	for _, name := range Names() {
+		opts, err := NewOptions(name)
+		require.NoError(t, err, name)
+		_, err = New(name, opts)
+		assert.NoError(t, err, name, "default options are valid")
+	}
+
+	opts, err := NewOptions(NameAverage)
+	require.NoError(t, err)
+	opts.Base().Precision = -1
+	_, err = New(NameAverage, opts)
+	assert.EqualError(t, err, "precision must be within [0, 18], got -1")
+
+	_, err = New(NameEMA, &Options{})
+	assert.EqualError(t, err, "unexpected options type *collector.Options")
+
+	_, err = NewOptions("best")
+	assert.Error(t, err)
+
+	c, err := New(NameAverage, nil)
+	require.NoError(t, err, "default options are used")
+	assert.NotNil(t, c)
+}
+
+func Test_Register_Custom(t *testing.T) {
+	t.Cleanup(func() {
+		registry.Lock()
+		defer registry.Unlock()
+		delete(registry.factories, "test-constant")
+	})
+	factory := Factory{
+		NewOptions: defaultOptions,
+		New: func(opts IOptions) (ICollector, error) {
+			return NewLatest(opts.Base().Precision), nil
+		},
+	}
+	Register("test-constant", factory)
+	assert.Contains(t, Names(), "test-constant")
+	assert.PanicsWithValue(t, `collector: Register called twice for "test-constant"`, func() { Register("test-constant", factory) })
+	assert.PanicsWithValue(t, `collector: Register called twice for "latest"`, func() { Register(NameLatest, factory) })
+	assert.PanicsWithValue(t, "collector: Register called with empty name or factory", func() { Register("test-empty", Factory{}) })
+}
@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SourceLatest keeps the latest by event time price of each source within the period
+// and aggregates these snapshots, so a single chatty source doesn't decide the price.
+type SourceLatest struct {
+	m sync.Mutex
+
+	aggregator IAggregator
+	weights    map[string]float64
+	precision  int
+	latest     map[string]Sample
+}
+
+// NewSourceLatest constructor
+func NewSourceLatest(precision int, aggregator IAggregator) *SourceLatest {
+	return &SourceLatest{
+		aggregator: aggregator,
+		precision:  precision,
+		latest:     map[string]Sample{},
+	}
+}
+
+// WithWeights sets weights of sources used by weighted aggregators, prices of sources without weight are ignored
+func (c *SourceLatest) WithWeights(weights map[string]float64) *SourceLatest {
+	c.weights = weights
+	return c
+}
+
+func (c *SourceLatest) Collect(price string, t time.Time) error {
+	return c.CollectTrade(Trade{Price: price, Time: t})
+}
+
+func (c *SourceLatest) CollectTrade(trade Trade) error {
+	sample, err := ParseSample(trade)
+	if err != nil {
+		return err
+	}
+	if c.weights != nil {
+		if sample.Weight = c.weights[sample.Source]; sample.Weight <= 0 {
+			return ErrNotCollected
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if prev, found := c.latest[sample.Source]; found && sample.Time.Before(prev.Time) {
+		return nil
+	}
+	c.latest[sample.Source] = sample
+	return nil
+}
+
+func (c *SourceLatest) GetFairPriceAndReset() string {
+	c.m.Lock()
+	samples := make([]Sample, 0, len(c.latest))
+	for _, sample := range c.latest {
+		samples = append(samples, sample)
+	}
+	c.latest = map[string]Sample{}
+	c.m.Unlock()
+
+	if len(samples) == 0 {
+		return NoValue
+	}
+	// aggregation result must not depend on map order
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Source < samples[j].Source })
+	value, ok := c.aggregator.Aggregate(samples)
+	if !ok {
+		return NoValue
+	}
+	return strconv.FormatFloat(value, 'f', c.precision, 64)
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SourceLatest(t *testing.T) {
+	c := NewSourceLatest(1, Mean())
+	assert.Equal(t, NoValue, c.GetFairPriceAndReset())
+
+	trades := []Trade{
+		{Source: "a", Price: "1", Time: tn},
+		{Source: "a", Price: "2", Time: tn.Add(2 * time.Second)},
+		{Source: "a", Price: "3", Time: tn.Add(time.Second)}, // late print of the same source
+		{Source: "b", Price: "10", Time: tn},
+	}
+	for _, trade := range trades {
+		require.NoError(t, c.CollectTrade(trade))
+	}
+	assert.Equal(t, "6.0", c.GetFairPriceAndReset())
+	assert.Equal(t, NoValue, c.GetFairPriceAndReset(), "snapshots are reset")
+}
+
+func Test_SourceLatest_Weights(t *testing.T) {
+	c := NewSourceLatest(1, WeightedMean()).WithWeights(map[string]float64{"a": 3, "b": 1})
+	require.NoError(t, c.CollectTrade(Trade{Source: "a", Price: "2", Size: "100", Time: tn}))
+	require.NoError(t, c.CollectTrade(Trade{Source: "b", Price: "6", Size: "1", Time: tn}))
+	assert.ErrorIs(t, c.CollectTrade(Trade{Source: "c", Price: "100", Time: tn}), ErrNotCollected, "no weight")
+	assert.Equal(t, "3.0", c.GetFairPriceAndReset())
+
+	assert.Error(t, c.CollectTrade(Trade{Source: "a", Price: "x"}))
+}
@@ -0,0 +1,237 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	FilterDeviation       = "deviation"
+	FilterLatestPerSource = "latest_per_source"
+	FilterTimeWindow      = "time_window"
+
+	AggregatorMean           = "mean"
+	AggregatorMedian         = "median"
+	AggregatorWeightedMean   = "weighted_mean"
+	AggregatorWeightedMedian = "weighted_median"
+	AggregatorLatest         = "latest"
+)
+
+// FilterSpec declares filter by name, it's used to build pipelines from config
+type FilterSpec struct {
+	Type   string        `yaml:"type"`
+	Max    float64       `yaml:"max"`    // deviation: max relative deviation from median, e.g. 0.01
+	Window time.Duration `yaml:"window"` // time_window: max age relative to the latest sample
+}
+
+// Build creates filter described by spec
+func (s FilterSpec) Build() (IFilter, error) {
+	switch s.Type {
+	case FilterDeviation:
+		if s.Max <= 0 {
+			return nil, fmt.Errorf("%s filter: max must be positive", s.Type)
+		}
+		return DeviationFilter(s.Max), nil
+	case FilterLatestPerSource:
+		return LatestPerSource(), nil
+	case FilterTimeWindow:
+		if s.Window <= 0 {
+			return nil, fmt.Errorf("%s filter: window must be positive", s.Type)
+		}
+		return TimeWindow(s.Window), nil
+	}
+	return nil, fmt.Errorf("unknown filter %q, expected one of %q, %q, %q",
+		s.Type, FilterDeviation, FilterLatestPerSource, FilterTimeWindow)
+}
+
+// AggregatorByName returns aggregator by its name
+func AggregatorByName(name string) (IAggregator, error) {
+	switch name {
+	case AggregatorMean:
+		return Mean(), nil
+	case AggregatorMedian:
+		return Median(), nil
+	case AggregatorWeightedMean:
+		return WeightedMean(), nil
+	case AggregatorWeightedMedian:
+		return WeightedMedian(), nil
+	case AggregatorLatest:
+		return LatestSample(), nil
+	}
+	return nil, fmt.Errorf("unknown aggregator %q, expected one of %q, %q, %q, %q, %q", name,
+		AggregatorMean, AggregatorMedian, AggregatorWeightedMean, AggregatorWeightedMedian, AggregatorLatest)
+}
+
+// DeviationFilter drops samples deviating from median price by more than maxDeviation (relative)
+func DeviationFilter(maxDeviation float64) FilterFunc {
+	return func(samples []Sample) []Sample {
+		median, _ := Median().Aggregate(samples)
+		result := samples[:0:0]
+		for _, s := range samples {
+			if median == 0 || math.Abs(s.Price-median)/math.Abs(median) <= maxDeviation {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+}
+
+// LatestPerSource keeps the latest by event time sample of each source
+func LatestPerSource() FilterFunc {
+	return func(samples []Sample) []Sample {
+		latest := map[string]int{}
+		result := samples[:0:0]
+		for _, s := range samples {
+			i, found := latest[s.Source]
+			if !found {
+				latest[s.Source] = len(result)
+				result = append(result, s)
+				continue
+			}
+			if !s.Time.Before(result[i].Time) {
+				result[i] = s
+			}
+		}
+		return result
+	}
+}
+
+// TimeWindow keeps samples not older than window relative to the latest sample
+func TimeWindow(window time.Duration) FilterFunc {
+	return func(samples []Sample) []Sample {
+		if len(samples) == 0 {
+			return samples
+		}
+		latest := samples[0].Time
+		for _, s := range samples[1:] {
+			if s.Time.After(latest) {
+				latest = s.Time
+			}
+		}
+		from := latest.Add(-window)
+		result := samples[:0:0]
+		for _, s := range samples {
+			if !s.Time.Before(from) {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+}
+
+// Mean is arithmetic average of prices
+func Mean() AggregatorFunc {
+	return func(samples []Sample) (float64, bool) {
+		if len(samples) == 0 {
+			return 0, false
+		}
+		sum := 0.
+		for _, s := range samples {
+			sum += s.Price
+		}
+		return sum / float64(len(samples)), true
+	}
+}
+
+// Median of prices, average of two middle ones for even number of samples
+func Median() AggregatorFunc {
+	return func(samples []Sample) (float64, bool) {
+		if len(samples) == 0 {
+			return 0, false
+		}
+		prices := make([]float64, 0, len(samples))
+		for _, s := range samples {
+			prices = append(prices, s.Price)
+		}
+		sort.Float64s(prices)
+		middle := len(prices) / 2
+		if len(prices)%2 == 1 {
+			return prices[middle], true
+		}
+		return (prices[middle-1] + prices[middle]) / 2, true
+	}
+}
+
+// WeightedMean is average of prices weighted by source weight or size (VWAP), see sampleWeights.
+func WeightedMean() AggregatorFunc {
+	return func(samples []Sample) (float64, bool) {
+		weights := sampleWeights(samples)
+		sum, total := 0., 0.
+		for i, s := range samples {
+			sum += s.Price * weights[i]
+			total += weights[i]
+		}
+		if total == 0 {
+			return 0, false
+		}
+		return sum / total, true
+	}
+}
+
+// WeightedMedian is the price at which cumulative weight reaches half of total weight, see sampleWeights.
+func WeightedMedian() AggregatorFunc {
+	return func(samples []Sample) (float64, bool) {
+		weights := sampleWeights(samples)
+		order := make([]int, len(samples))
+		total := 0.
+		for i := range samples {
+			order[i] = i
+			total += weights[i]
+		}
+		if total == 0 {
+			return 0, false
+		}
+		sort.SliceStable(order, func(a, b int) bool { return samples[order[a]].Price < samples[order[b]].Price })
+		cumulative := 0.
+		for n, i := range order {
+			cumulative += weights[i]
+			if cumulative*2 == total && n+1 < len(order) {
+				return (samples[i].Price + samples[order[n+1]].Price) / 2, true
+			}
+			if cumulative*2 >= total {
+				return samples[i].Price, true
+			}
+		}
+		return samples[order[len(order)-1]].Price, true
+	}
+}
+
+// LatestSample is the price of the latest by event time sample
+func LatestSample() AggregatorFunc {
+	return func(samples []Sample) (float64, bool) {
+		if len(samples) == 0 {
+			return 0, false
+		}
+		latest := samples[0]
+		for _, s := range samples[1:] {
+			if !s.Time.Before(latest.Time) {
+				latest = s
+			}
+		}
+		return latest.Price, true
+	}
+}
+
+// sampleWeights uses source weights if all samples have them, otherwise sizes if all samples have them,
+// otherwise samples are weighted equally
+func sampleWeights(samples []Sample) []float64 {
+	weighted, sized := true, true
+	for _, s := range samples {
+		weighted = weighted && s.Weight > 0
+		sized = sized && s.Size > 0
+	}
+	weights := make([]float64, len(samples))
+	for i, s := range samples {
+		switch {
+		case weighted:
+			weights[i] = s.Weight
+		case sized:
+			weights[i] = s.Size
+		default:
+			weights[i] = 1
+		}
+	}
+	return weights
+}
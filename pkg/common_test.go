@@ -120,8 +120,8 @@ func Test_TableAveragePrices(t *testing.T) {
 			resultCh := m.Subscribe(valuesToStreams(tst.streams))
 			c := collector.NewAverage(3)
 			p := NewFairPrice(c, fixedTimeNow)
-			result := []TickerPrice{}
-			output, wait := make(chan TickerPrice, 1), make(chan struct{})
+			result := []FairPriceResult{}
+			output, wait := make(chan FairPriceResult, 1), make(chan struct{})
 			go func() {
 				for p := range output {
 					result = append(result, p)
@@ -246,8 +246,8 @@ func Test_TableLatestPrice(t *testing.T) {
 			resultCh := m.Subscribe(valuesToStreams(tst.streams))
 			c := collector.NewLatest(3)
 			p := NewFairPrice(c, fixedTimeNow)
-			result := []TickerPrice{}
-			output, wait := make(chan TickerPrice, 1), make(chan struct{})
+			result := []FairPriceResult{}
+			output, wait := make(chan FairPriceResult, 1), make(chan struct{})
 			go func() {
 				for p := range output {
 					result = append(result, p)
@@ -285,7 +285,7 @@ func valuesToStreams(blocks [][]interface{}) (result []IPriceStreamSubscriber) {
 	return result
 }
 
-func toPrices(values []TickerPrice) (result []string) {
+func toPrices(values []FairPriceResult) (result []string) {
 	for _, val := range values {
 		result = append(result, val.Price)
 	}
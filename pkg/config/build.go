@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/collector"
+	"gopkg.in/yaml.v3"
+)
+
+// Build creates new collector instance, every ticker needs its own one
+func (c CollectorConfig) Build() (pkg.IFairPriceCollector, error) {
+	opts, err := collector.NewOptions(c.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	if c.Options.Kind != 0 {
+		if err := c.decodeOptions(opts); err != nil {
+			return nil, err
+		}
+	}
+	if c.Precision != nil {
+		opts.Base().Precision = *c.Precision
+	}
+	return collector.New(c.Strategy, opts)
+}
+
+// decodeOptions decodes section into options reporting unknown fields
+func (c CollectorConfig) decodeOptions(opts collector.IOptions) error {
+	if err := c.Options.Decode(opts); err != nil {
+		return err
+	}
+	known := map[string]struct{}{"strategy": {}}
+	optionKeys(reflect.TypeOf(opts), known)
+	if key := unknownKey(c.Options, known); key != nil {
+		return fmt.Errorf("line %d: unknown option %q of %q strategy", key.Line, key.Value, c.Strategy)
+	}
+	return nil
+}
+
+// unknownKey returns the first key of mapping node which isn't among known ones, nil if there is none
+func unknownKey(node yaml.Node, known map[string]struct{}) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if _, found := known[node.Content[i].Value]; !found {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+// optionKeys collects YAML keys of struct fields
+func optionKeys(t reflect.Type, keys map[string]struct{}) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		switch {
+		case len(tag) > 1 && tag[1] == "inline":
+			optionKeys(field.Type, keys)
+		case tag[0] == "-":
+		case tag[0] == "":
+			keys[strings.ToLower(field.Name)] = struct{}{}
+		default:
+			keys[tag[0]] = struct{}{}
+		}
+	}
+}
+
+// Build creates new policy instance, every ticker needs its own one
+func (c EmptyPeriodConfig) Build() (pkg.EmptyPeriodPolicy, error) {
+	policy := pkg.EmptyPeriodPolicy{
+		CarryForward: c.CarryForward,
+		MaxPeriods:   c.MaxPeriods,
+	}
+	if c.Fallback != nil {
+		fallback, err := c.Fallback.Collector.Build()
+		if err != nil {
+			return policy, err
+		}
+		policy.Fallback, policy.FallbackSources = fallback, c.Fallback.Sources
+	}
+	return policy, nil
+}
+
+// Build creates new policy instance with collectors of backup groups, every ticker needs its own one.
+// Backup groups without collector config use the main one.
+func (c FailoverConfig) Build(main CollectorConfig) (pkg.FailoverPolicy, error) {
+	policy := pkg.FailoverPolicy{FailAfter: c.FailAfter, RecoverAfter: c.RecoverAfter}
+	for i, group := range c.Groups {
+		result := pkg.SourceGroup{Name: group.Name, Sources: group.Sources}
+		if i > 0 {
+			collectorConfig := main
+			if group.Collector != nil {
+				collectorConfig = *group.Collector
+			}
+			var err error
+			if result.Collector, err = collectorConfig.Build(); err != nil {
+				return policy, err
+			}
+		}
+		policy.Groups = append(policy.Groups, result)
+	}
+	return policy, policy.Validate()
+}
+
+// Build creates converter, it returns nil if there are no rate tickers
+func (c ConversionConfig) Build(tn func() time.Time) *pkg.QuoteConverter {
+	if len(c.Tickers) == 0 {
+		return nil
+	}
+	converter := pkg.NewQuoteConverter(tn).WithMaxAge(c.MaxAge)
+	for _, ticker := range c.Tickers {
+		converter.WithRate(ticker)
+	}
+	return converter
+}
+
+// Build returns breaker options, defaults are used for omitted ones
+func (c BreakerConfig) Build() pkg.BreakerOptions {
+	opts := pkg.DefaultBreakerOptions()
+	opts.Failures, opts.MaxDeviation = c.Failures, c.MaxDeviation
+	if c.Window > 0 {
+		opts.Window = c.Window
+	}
+	if c.Cooldown > 0 {
+		opts.Cooldown = c.Cooldown
+	}
+	if c.Probes > 0 {
+		opts.Probes = c.Probes
+	}
+	return opts
+}
+
+// Build creates cross rate described by config
+func (c CrossRateConfig) Build() pkg.CrossRate {
+	rate := pkg.CrossRate{Ticker: c.Ticker, Precision: 3}
+	if c.Precision != nil {
+		rate.Precision = *c.Precision
+	}
+	for _, leg := range c.Legs {
+		rate.Legs = append(rate.Legs, pkg.Leg{Ticker: leg.Ticker, Invert: leg.Invert})
+	}
+	return rate
+}
+
+// Build creates source described by config
+func (c SourceConfig) Build() (pkg.IPriceStreamSubscriberV2, error) {
+	switch c.Type {
+	case SourceRandom:
+		params := RandomSourceParams{}
+		opts := pkg.DefaultMockRandomOptions()
+		if c.Params.Kind != 0 {
+			if err := c.Params.Decode(&params); err != nil {
+				return nil, fmt.Errorf("params: %w", err)
+			}
+			known := map[string]struct{}{}
+			optionKeys(reflect.TypeOf(params), known)
+			if key := unknownKey(c.Params, known); key != nil {
+				return nil, fmt.Errorf("params: line %d: unknown param %q of %q source", key.Line, key.Value, c.Type)
+			}
+		}
+		if params.Interval < 0 || params.Range < 0 || params.Base < 0 || params.Depth < 0 {
+			return nil, fmt.Errorf("params: interval, base, range and depth must not be negative")
+		}
+		if params.Interval > 0 {
+			opts.Interval = params.Interval
+		}
+		if params.Base > 0 {
+			opts.Base = params.Base
+		}
+		if params.Range > 0 {
+			opts.Range = params.Range
+		}
+		opts.Bases, opts.Depth, opts.Quote, opts.Sequence = params.Bases, params.Depth, params.Quote, params.Sequence
+		if params.Symbols != "" {
+			convention, found := pkg.Conventions[params.Symbols]
+			if !found {
+				return nil, fmt.Errorf("params: unknown symbols %q, expected one of \"canonical\", \"concat\", \"slash\", \"dash\"", params.Symbols)
+			}
+			opts.Symbols = &convention
+		}
+		return pkg.NewMockRandomStreamWithOptions(opts), nil
+	case "":
+		return nil, fmt.Errorf("type is required")
+	}
+	return nil, fmt.Errorf("unknown type %q, expected %q", c.Type, SourceRandom)
+}
@@ -0,0 +1,514 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/collector"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SourceRandom = "random"
+
+	OutputStdout = "stdout"
+	OutputStore  = "store"
+
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Config of the application
+type Config struct {
+	Tickers []pkg.Ticker  `yaml:"tickers"`
+	Period  time.Duration `yaml:"period"`
+	Quorum  int           `yaml:"quorum"`
+	// quote assets treated as equal without conversion, e.g. {USDT: USD}
+	QuoteEquivalence map[string]string `yaml:"quote_equivalence"`
+	Collector        CollectorConfig   `yaml:"collector"`
+	EmptyPeriod      EmptyPeriodConfig `yaml:"empty_period"`
+	Failover         *FailoverConfig   `yaml:"failover"`
+	Dedup            DedupConfig       `yaml:"dedup"`
+	Sequence         SequenceConfig    `yaml:"sequence"`
+	Breaker          BreakerConfig     `yaml:"breaker"`
+	RateLimit        *RateLimitConfig  `yaml:"rate_limit"`
+	Conversion       ConversionConfig  `yaml:"conversion"`
+	CrossRates       []CrossRateConfig `yaml:"cross_rates"`
+	Sources          []SourceConfig    `yaml:"sources"`
+	Outputs          []OutputConfig    `yaml:"outputs"`
+	API              APIConfig         `yaml:"api"`
+}
+
+// CollectorConfig selects registered collector by strategy name,
+// other fields of the section are decoded into typed options of the collector
+type CollectorConfig struct {
+	Strategy  string
+	Precision *int      // overrides precision of options if set
+	Options   yaml.Node // whole section, decoded on build
+}
+
+// EmptyPeriodConfig describes pkg.EmptyPeriodPolicy
+type EmptyPeriodConfig struct {
+	CarryForward bool            `yaml:"carry_forward"`
+	MaxPeriods   int             `yaml:"max_periods"`
+	Fallback     *FallbackConfig `yaml:"fallback"`
+}
+
+type FallbackConfig struct {
+	Collector CollectorConfig `yaml:"collector"`
+	Sources   []string        `yaml:"sources"` // secondary source group, all sources if empty
+}
+
+// FailoverConfig describes pkg.FailoverPolicy
+type FailoverConfig struct {
+	Groups       []SourceGroupConfig `yaml:"groups"`        // in order of priority, the first one is primary
+	FailAfter    int                 `yaml:"fail_after"`    // periods missing quorum before failing over, 1 if 0
+	RecoverAfter int                 `yaml:"recover_after"` // periods of higher group meeting quorum before switching back
+}
+
+type SourceGroupConfig struct {
+	Name      string           `yaml:"name"`
+	Sources   []string         `yaml:"sources"`
+	Collector *CollectorConfig `yaml:"collector"` // backup groups only, main collector config is used if omitted
+}
+
+// DedupConfig enables dropping of repeated prints by multiplexor
+type DedupConfig struct {
+	Window     time.Duration `yaml:"window"`      // prints are remembered for the window, dedup is disabled if 0
+	MaxEntries int           `yaml:"max_entries"` // max number of remembered prints per ticker, unlimited if 0
+}
+
+// SequenceConfig describes handling of gaps in sequence numbers of source prices
+type SequenceConfig struct {
+	ResubscribeOnGap bool `yaml:"resubscribe_on_gap"`
+}
+
+// BreakerConfig enables per-source circuit breaker, omitted options are defaults
+type BreakerConfig struct {
+	Failures     int           `yaml:"failures"`      // failures within the window opening the breaker, disabled if 0
+	Window       time.Duration `yaml:"window"`        // failures are counted within the window
+	Cooldown     time.Duration `yaml:"cooldown"`      // time the source is quarantined before probing
+	Probes       int           `yaml:"probes"`        // accepted prices closing half-open breaker
+	MaxDeviation float64       `yaml:"max_deviation"` // relative deviation from other sources, not checked if 0
+}
+
+// RateLimitConfig enables fair scheduling of prices of sources, optionally rate limited and coalesced
+type RateLimitConfig struct {
+	Interval  time.Duration `yaml:"interval"`   // min interval between prices of a source, not limited if 0
+	Coalesce  bool          `yaml:"coalesce"`   // only the latest waiting trade and quote of a source are kept
+	QueueSize int           `yaml:"queue_size"` // max waiting prices of a source without coalescing, 1 if 0
+}
+
+// ConversionConfig describes pkg.QuoteConverter. Prices quoted in other assets are converted
+// into quote of the ticker using fair prices of rate tickers, e.g. BTC_USDT into BTC_USD using USDT_USD.
+type ConversionConfig struct {
+	Tickers []pkg.Ticker  `yaml:"tickers"` // rate tickers, must be listed in tickers
+	MaxAge  time.Duration `yaml:"max_age"` // prices aren't converted with older rates, unlimited if 0
+}
+
+// CrossRateConfig describes synthetic ticker calculated from fair prices of configured tickers
+type CrossRateConfig struct {
+	Ticker    pkg.Ticker  `yaml:"ticker"`
+	Legs      []LegConfig `yaml:"legs"`
+	Precision *int        `yaml:"precision"` // 3 if not set
+}
+
+type LegConfig struct {
+	Ticker pkg.Ticker `yaml:"ticker"`
+	Invert bool       `yaml:"invert"`
+}
+
+type SourceConfig struct {
+	Name   string    `yaml:"name"`
+	Type   string    `yaml:"type"`
+	Params yaml.Node `yaml:"params"` // type specific, decoded on build
+}
+
+type OutputConfig struct {
+	Type   string `yaml:"type"`
+	Format string `yaml:"format"` // stdout only
+	Path   string `yaml:"path"`   // store only, directory of segment files
+}
+
+type APIConfig struct {
+	Listen      string        `yaml:"listen"` // API is disabled if empty
+	HistorySize int           `yaml:"history_size"`
+	StaleAfter  time.Duration `yaml:"stale_after"` // 3 periods if 0, see Config.StaleAfter
+}
+
+func (c *CollectorConfig) UnmarshalYAML(node *yaml.Node) error {
+	section := struct {
+		Strategy  string `yaml:"strategy"`
+		Precision *int   `yaml:"precision"`
+	}{Strategy: c.Strategy, Precision: c.Precision}
+	if err := node.Decode(&section); err != nil {
+		return err
+	}
+	c.Strategy, c.Precision, c.Options = section.Strategy, section.Precision, *node
+	return nil
+}
+
+// RandomSourceParams are params of "random" source
+type RandomSourceParams struct {
+	Interval time.Duration          `yaml:"interval"`
+	Base     float64                `yaml:"base"`
+	Range    float64                `yaml:"range"`
+	Bases    map[pkg.Ticker]float64 `yaml:"bases"`
+	Depth    int                    `yaml:"depth"`    // order book levels, quote events aren't generated if 0
+	Quote    string                 `yaml:"quote"`    // quote asset of sent prices, e.g. USDT
+	Symbols  string                 `yaml:"symbols"`  // convention of sent tickers: canonical, concat, slash or dash
+	Sequence bool                   `yaml:"sequence"` // prices are numbered
+}
+
+// ValidationError lists all problems found in config
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid config:\n  " + strings.Join(e, "\n  ")
+}
+
+// Default returns config equal to the hard-coded setup of the application
+func Default() *Config {
+	cfg := &Config{
+		Tickers:   []pkg.Ticker{pkg.BTCUSDTicker},
+		Period:    5 * time.Second,
+		Collector: CollectorConfig{Strategy: collector.NameLatest},
+		Outputs:   []OutputConfig{{Type: OutputStdout, Format: FormatText}},
+	}
+	for i := 1; i <= 5; i++ {
+		cfg.Sources = append(cfg.Sources, SourceConfig{Name: fmt.Sprintf("random-%d", i), Type: SourceRandom})
+	}
+	cfg.setDefaults()
+	return cfg
+}
+
+// Load reads and validates config file
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse decodes and validates YAML config. Unknown fields are reported as errors.
+func Parse(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		Collector: CollectorConfig{Strategy: collector.NameLatest},
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return nil, err
+	}
+	cfg.setDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) setDefaults() {
+	for i, ticker := range c.Tickers {
+		c.Tickers[i] = canonicalTicker(ticker)
+	}
+	for i, ticker := range c.Conversion.Tickers {
+		c.Conversion.Tickers[i] = canonicalTicker(ticker)
+	}
+	for i := range c.CrossRates {
+		c.CrossRates[i].Ticker = canonicalTicker(c.CrossRates[i].Ticker)
+		for j := range c.CrossRates[i].Legs {
+			c.CrossRates[i].Legs[j].Ticker = canonicalTicker(c.CrossRates[i].Legs[j].Ticker)
+		}
+	}
+	if len(c.Outputs) == 0 {
+		c.Outputs = []OutputConfig{{Type: OutputStdout, Format: FormatText}}
+	}
+	for i := range c.Outputs {
+		if c.Outputs[i].Format == "" {
+			c.Outputs[i].Format = FormatText
+		}
+	}
+}
+
+// Validate checks config and returns ValidationError with all found problems
+func (c *Config) Validate() error {
+	var errs ValidationError
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if len(c.Tickers) == 0 {
+		add("tickers: at least one ticker is required")
+	}
+	tickers := map[pkg.Ticker]struct{}{}
+	for i, ticker := range c.Tickers {
+		if ticker == "" {
+			add("tickers[%d]: empty ticker", i)
+		} else if _, err := ticker.Pair(); err != nil {
+			add("tickers[%d]: %v", i, err)
+		}
+		if _, found := tickers[ticker]; found {
+			add("tickers[%d]: duplicated ticker %q", i, ticker)
+		}
+		tickers[ticker] = struct{}{}
+	}
+	if c.Period <= 0 {
+		add("period: must be positive duration, e.g. \"5s\"")
+	}
+	if c.Quorum < 0 {
+		add("quorum: must not be negative")
+	}
+	for asset, equivalent := range c.QuoteEquivalence {
+		if asset == "" || equivalent == "" {
+			add("quote_equivalence: empty asset")
+		}
+	}
+	if _, err := c.Collector.Build(); err != nil {
+		add("collector: %v", err)
+	}
+
+	if c.Dedup.Window < 0 {
+		add("dedup.window: must not be negative")
+	}
+	if c.Dedup.MaxEntries < 0 {
+		add("dedup.max_entries: must not be negative")
+	}
+	if c.Breaker.Failures < 0 {
+		add("breaker.failures: must not be negative")
+	}
+	if c.Breaker.Window < 0 {
+		add("breaker.window: must not be negative")
+	}
+	if c.Breaker.Cooldown < 0 {
+		add("breaker.cooldown: must not be negative")
+	}
+	if c.Breaker.Probes < 0 {
+		add("breaker.probes: must not be negative")
+	}
+	if c.Breaker.MaxDeviation < 0 {
+		add("breaker.max_deviation: must not be negative")
+	}
+	if c.RateLimit != nil && c.RateLimit.Interval < 0 {
+		add("rate_limit.interval: must not be negative")
+	}
+	if c.RateLimit != nil && c.RateLimit.QueueSize < 0 {
+		add("rate_limit.queue_size: must not be negative")
+	}
+	for i, ticker := range c.Conversion.Tickers {
+		if !c.hasTicker(ticker) {
+			add("conversion.tickers[%d]: unknown ticker %q", i, ticker)
+		}
+	}
+	if c.Conversion.MaxAge < 0 {
+		add("conversion.max_age: must not be negative")
+	}
+	for i, rate := range c.CrossRates {
+		if rate.Ticker == "" {
+			add("cross_rates[%d].ticker: ticker is required", i)
+		}
+		if _, found := tickers[rate.Ticker]; found && rate.Ticker != "" {
+			add("cross_rates[%d].ticker: duplicated ticker %q", i, rate.Ticker)
+		}
+		if len(rate.Legs) == 0 {
+			add("cross_rates[%d].legs: at least one leg is required", i)
+		}
+		for j, leg := range rate.Legs {
+			if !c.hasTicker(leg.Ticker) {
+				add("cross_rates[%d].legs[%d]: unknown ticker %q", i, j, leg.Ticker)
+			}
+		}
+		if p := rate.Build().Precision; p < 0 || p > 18 {
+			add("cross_rates[%d].precision: must be within [0, 18], got %d", i, p)
+		}
+		tickers[rate.Ticker] = struct{}{}
+	}
+
+	if c.EmptyPeriod.MaxPeriods < 0 {
+		add("empty_period.max_periods: must not be negative")
+	}
+	if c.EmptyPeriod.MaxPeriods > 0 && !c.EmptyPeriod.CarryForward {
+		add("empty_period.max_periods: makes sense only with carry_forward")
+	}
+	if fallback := c.EmptyPeriod.Fallback; fallback != nil {
+		if _, err := fallback.Collector.Build(); err != nil {
+			add("empty_period.fallback.collector: %v", err)
+		}
+		for i, name := range fallback.Sources {
+			if !c.hasSource(name) {
+				add("empty_period.fallback.sources[%d]: unknown source %q", i, name)
+			}
+		}
+	}
+
+	if failover := c.Failover; failover != nil {
+		if len(failover.Groups) < 2 {
+			add("failover.groups: primary and at least one backup group are required")
+		}
+		groups, grouped := map[string]struct{}{}, map[string]string{}
+		for i, group := range failover.Groups {
+			if group.Name == "" {
+				add("failover.groups[%d].name: name is required", i)
+			}
+			if _, found := groups[group.Name]; found && group.Name != "" {
+				add("failover.groups[%d].name: duplicated name %q", i, group.Name)
+			}
+			groups[group.Name] = struct{}{}
+			if len(group.Sources) == 0 {
+				add("failover.groups[%d].sources: at least one source is required", i)
+			}
+			for j, name := range group.Sources {
+				if !c.hasSource(name) {
+					add("failover.groups[%d].sources[%d]: unknown source %q", i, j, name)
+				}
+				if other, found := grouped[name]; found {
+					add("failover.groups[%d].sources[%d]: source %q is already in group %q", i, j, name, other)
+				}
+				if c.EmptyPeriod.Fallback != nil && contains(c.EmptyPeriod.Fallback.Sources, name) {
+					add("failover.groups[%d].sources[%d]: source %q is in empty_period.fallback.sources", i, j, name)
+				}
+				grouped[name] = group.Name
+			}
+			switch {
+			case group.Collector == nil:
+			case i == 0:
+				add("failover.groups[0].collector: primary group uses the main collector")
+			default:
+				if _, err := group.Collector.Build(); err != nil {
+					add("failover.groups[%d].collector: %v", i, err)
+				}
+			}
+		}
+		if failover.FailAfter < 0 {
+			add("failover.fail_after: must not be negative")
+		}
+		if failover.RecoverAfter < 0 {
+			add("failover.recover_after: must not be negative")
+		}
+	}
+
+	if len(c.Sources) == 0 {
+		add("sources: at least one source is required")
+	}
+	names := map[string]struct{}{}
+	for i, src := range c.Sources {
+		if src.Name == "" {
+			add("sources[%d].name: name is required", i)
+		}
+		if _, found := names[src.Name]; found && src.Name != "" {
+			add("sources[%d].name: duplicated name %q", i, src.Name)
+		}
+		names[src.Name] = struct{}{}
+		if _, err := src.Build(); err != nil {
+			add("sources[%d] (%s): %v", i, src.Name, err)
+		}
+	}
+
+	for i, output := range c.Outputs {
+		if err := output.validate(); err != nil {
+			add("outputs[%d]: %v", i, err)
+		}
+	}
+
+	if c.API.HistorySize < 0 {
+		add("api.history_size: must not be negative")
+	}
+	if c.API.StaleAfter < 0 {
+		add("api.stale_after: must not be negative")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// canonicalTicker converts ticker written in any notation to canonical form, unparsable ones are kept for validation
+func canonicalTicker(ticker pkg.Ticker) pkg.Ticker {
+	if pair, err := ticker.Pair(); err == nil {
+		return pair.Ticker()
+	}
+	return ticker
+}
+
+// StaleAfter returns age of the latest price after which API reports it stale.
+// It's derived from the period when not set, so it follows the period overridden after loading.
+func (c *Config) StaleAfter() time.Duration {
+	if c.API.StaleAfter == 0 {
+		return 3 * c.Period
+	}
+	return c.API.StaleAfter
+}
+
+// Equivalence returns quote equivalence with canonical asset codes
+func (c *Config) Equivalence() pkg.QuoteEquivalence {
+	if len(c.QuoteEquivalence) == 0 {
+		return nil
+	}
+	result := pkg.QuoteEquivalence{}
+	for asset, equivalent := range c.QuoteEquivalence {
+		result[pkg.CanonicalAsset(asset)] = pkg.CanonicalAsset(equivalent)
+	}
+	return result
+}
+
+func (c *Config) hasTicker(ticker pkg.Ticker) bool {
+	for _, t := range c.Tickers {
+		if t == ticker {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) hasSource(name string) bool {
+	for _, src := range c.Sources {
+		if src.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (o OutputConfig) validate() error {
+	switch o.Type {
+	case OutputStdout:
+	case OutputStore:
+		if o.Path == "" {
+			return fmt.Errorf("path is required for %q output", OutputStore)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output type %q, expected one of %q, %q", o.Type, OutputStdout, OutputStore)
+	}
+	switch o.Format {
+	case FormatText, FormatJSON, FormatCSV:
+	default:
+		return fmt.Errorf("unknown format %q, expected one of %q, %q, %q", o.Format, FormatText, FormatJSON, FormatCSV)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadExample(t *testing.T) {
+	cfg, err := Load("../../config.example.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []pkg.Ticker{pkg.BTCUSDTicker, "ETH_USD"}, cfg.Tickers)
+	assert.Equal(t, 5*time.Second, cfg.Period)
+	assert.Len(t, cfg.Sources, 2)
+	assert.Equal(t, ":8080", cfg.API.Listen)
+}
+
+func Test_Default_IsValid(t *testing.T) {
+	assert.NoError(t, Default().Validate())
+}
+
+func Test_StaleAfter_FollowsPeriod(t *testing.T) {
+	cfg := Default()
+	cfg.Period = time.Minute
+	assert.Equal(t, 3*time.Minute, cfg.StaleAfter())
+	cfg.API.StaleAfter = time.Second
+	assert.Equal(t, time.Second, cfg.StaleAfter())
+}
+
+func Test_Parse_ReportsAllProblems(t *testing.T) {
+	_, err := Parse(strings.NewReader(`
+tickers: [BTC_USD, BTC_USD]
+period: 0s
+collector:
+  strategy: best
+dedup: {window: -1s}
+breaker: {failures: 3, max_deviation: -0.1}
+conversion:
+  tickers: [USDT_USD]
+cross_rates:
+  - ticker: BTC_USD
+    legs: [{ticker: ETH_USD}]
+  - ticker: BTC_EUR
+empty_period:
+  max_periods: 2
+  fallback:
+    collector: {strategy: average}
+    sources: [z]
+sources:
+  - name: a
+    type: random
+  - name: a
+    type: ftp
+  - name: b
+    type: random
+    params:
+      interval: -1s
+outputs:
+  - type: kafka
+  - type: store
+`))
+	require.Error(t, err)
+	require.IsType(t, ValidationError{}, err)
+	assert.Equal(t, ValidationError{
+		`tickers[1]: duplicated ticker "BTC_USD"`,
+		`period: must be positive duration, e.g. "5s"`,
+		`collector: unknown strategy "best", expected one of "average", "depth_mid", "ema", "impact", "latest", "median", "micro_price", "mid", "ohlc", "pipeline", "source_latest"`,
+		`dedup.window: must not be negative`,
+		`breaker.max_deviation: must not be negative`,
+		`conversion.tickers[0]: unknown ticker "USDT_USD"`,
+		`cross_rates[0].ticker: duplicated ticker "BTC_USD"`,
+		`cross_rates[0].legs[0]: unknown ticker "ETH_USD"`,
+		`cross_rates[1].legs: at least one leg is required`,
+		`empty_period.max_periods: makes sense only with carry_forward`,
+		`empty_period.fallback.sources[0]: unknown source "z"`,
+		`sources[1].name: duplicated name "a"`,
+		`sources[1] (a): unknown type "ftp", expected "random"`,
+		`sources[2] (b): params: interval, base, range and depth must not be negative`,
+		`outputs[0]: unknown output type "kafka", expected one of "stdout", "store"`,
+		`outputs[1]: path is required for "store" output`,
+	}, err)
+}
+
+func Test_Parse_UnknownField(t *testing.T) {
+	_, err := Parse(strings.NewReader("tickers: [BTC_USD]\nperiod: 1s\nsourcez: []\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sourcez")
+}
+
+func Test_Parse_UnknownSourceParam(t *testing.T) {
+	_, err := Parse(strings.NewReader(`
+tickers: [BTC_USD]
+period: 1s
+sources:
+  - name: a
+    type: random
+    params: {intervall: 1s}
+`))
+	assert.Equal(t, ValidationError{`sources[0] (a): params: line 7: unknown param "intervall" of "random" source`}, err)
+}
+
+func Test_Default_APIDisabled(t *testing.T) {
+	assert.Empty(t, Default().API.Listen)
+}
+
+func Test_CollectorOptions(t *testing.T) {
+	cfg, err := Parse(strings.NewReader(`
+tickers: [BTC_USD]
+period: 1s
+collector:
+  strategy: pipeline
+  precision: 2
+  filters:
+    - {type: deviation, max: 0.05}
+    - {type: latest_per_source}
+  aggregator: median
+sources: [{name: a, type: random}]
+`))
+	require.NoError(t, err)
+	c, err := cfg.Collector.Build()
+	require.NoError(t, err)
+	require.NoError(t, c.Collect("1", time.Now()))
+	assert.Equal(t, "1.00", c.GetFairPriceAndReset())
+
+	precision := 1
+	cfg.Collector.Precision = &precision
+	c, err = cfg.Collector.Build()
+	require.NoError(t, err)
+	require.NoError(t, c.Collect("1", time.Now()))
+	assert.Equal(t, "1.0", c.GetFairPriceAndReset())
+
+	_, err = Parse(strings.NewReader(`
+tickers: [BTC_USD]
+period: 1s
+collector:
+  strategy: latest
+  half_life: 1m
+  precision: 30
+empty_period:
+  fallback:
+    collector: {strategy: ema, half_life: 0s}
+sources: [{name: a, type: random}]
+`))
+	assert.Equal(t, ValidationError{
+		`collector: line 6: unknown option "half_life" of "latest" strategy`,
+		`empty_period.fallback.collector: half_life must be positive duration`,
+	}, err)
+}
+
+func Test_Parse_Tickers(t *testing.T) {
+	cfg, err := Parse(strings.NewReader(`
+tickers: [btc-usd, XBT/EUR]
+period: 1s
+quote_equivalence: {usdt: usd}
+sources: [{name: a, type: random, params: {quote: USDT, symbols: concat}}]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []pkg.Ticker{pkg.BTCUSDTicker, "BTC_EUR"}, cfg.Tickers)
+	assert.Equal(t, pkg.QuoteEquivalence{"USDT": "USD"}, cfg.Equivalence())
+
+	_, err = Parse(strings.NewReader(`
+tickers: [BTC]
+period: 1s
+sources: [{name: a, type: random, params: {symbols: weird}}]
+`))
+	assert.Equal(t, ValidationError{
+		`tickers[0]: can't parse pair "BTC", expected BASE_QUOTE`,
+		`sources[0] (a): params: unknown symbols "weird", expected one of "canonical", "concat", "slash", "dash"`,
+	}, err)
+}
+
+func Test_Parse_Failover(t *testing.T) {
+	cfg, err := Parse(strings.NewReader(`
+tickers: [BTC_USD]
+period: 1s
+failover:
+  groups:
+    - {name: primary, sources: [a]}
+    - {name: backup, sources: [b], collector: {strategy: median}}
+  recover_after: 3
+sources: [{name: a, type: random}, {name: b, type: random}]
+`))
+	require.NoError(t, err)
+	policy, err := cfg.Failover.Build(cfg.Collector)
+	require.NoError(t, err)
+	require.Len(t, policy.Groups, 2)
+	assert.Nil(t, policy.Groups[0].Collector, "main collector is used")
+	assert.NotNil(t, policy.Groups[1].Collector)
+	assert.Equal(t, 3, policy.RecoverAfter)
+
+	_, err = Parse(strings.NewReader(`
+tickers: [BTC_USD]
+period: 1s
+failover:
+  groups:
+    - {name: primary, sources: [a, z], collector: {strategy: median}}
+    - {name: primary, sources: [a]}
+    - {name: backup, sources: [b]}
+empty_period:
+  fallback: {collector: {strategy: latest}, sources: [b]}
+sources: [{name: a, type: random}, {name: b, type: random}]
+`))
+	assert.Equal(t, ValidationError{
+		`failover.groups[0].sources[1]: unknown source "z"`,
+		`failover.groups[0].collector: primary group uses the main collector`,
+		`failover.groups[1].name: duplicated name "primary"`,
+		`failover.groups[1].sources[0]: source "a" is already in group "primary"`,
+		`failover.groups[2].sources[0]: source "b" is in empty_period.fallback.sources`,
+	}, err)
+}
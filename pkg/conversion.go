@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+)
+
+var (
+	ErrNotConvertible    = errors.New("no conversion between pairs")
+	ErrNoConversionRate  = errors.New("no conversion rate")
+	ErrConversionRateOld = errors.New("conversion rate is too old")
+)
+
+// Conversion is recorded on prices converted into another quote asset
+type Conversion struct {
+	From       Ticker        // original ticker of the price, e.g. BTC_USDT
+	RateTicker Ticker        // ticker of the rate, e.g. USDT_USD
+	Rate       string        // fair price of rate ticker used for conversion
+	RateAge    time.Duration // age of the rate at the moment of conversion
+}
+
+type conversionRate struct {
+	value float64
+	price string
+	time  time.Time
+}
+
+// QuoteConverter converts prices quoted in one asset into another one using fair prices of conversion tickers,
+// e.g. BTC_USDT into BTC_USD using USDT_USD. A rate ticker works in both directions.
+type QuoteConverter struct {
+	m sync.RWMutex
+
+	timeNow timeNow
+	maxAge  time.Duration
+	rates   map[Pair]*conversionRate
+}
+
+// NewQuoteConverter constructor
+func NewQuoteConverter(tn timeNow) *QuoteConverter {
+	return &QuoteConverter{
+		timeNow: tn,
+		rates:   map[Pair]*conversionRate{},
+	}
+}
+
+// WithRate registers conversion ticker, its prices must be passed to Update
+func (c *QuoteConverter) WithRate(ticker Ticker) *QuoteConverter {
+	if pair, err := ticker.Pair(); err == nil {
+		c.rates[pair] = &conversionRate{}
+	}
+	return c
+}
+
+// WithMaxAge sets max age of rate, prices aren't converted with older ones. Age isn't limited if 0.
+func (c *QuoteConverter) WithMaxAge(maxAge time.Duration) *QuoteConverter {
+	c.maxAge = maxAge
+	return c
+}
+
+// Update consumes fair price of the rate ticker, other prices and prices without value are ignored
+func (c *QuoteConverter) Update(price FairPriceResult) {
+	pair, err := price.Ticker.Pair()
+	if err != nil {
+		return
+	}
+	value, err := strconv.ParseFloat(price.Price, 64)
+	if err != nil || value <= 0 || price.Status == StatusNoValue {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if rate, found := c.rates[pair]; found {
+		rate.value, rate.price, rate.time = value, price.Price, price.Time
+	}
+}
+
+// Convert converts the price into target quote asset and records the conversion on it.
+// ErrNotConvertible is returned if assets differ, there is no rate ticker between quotes or target is a rate ticker.
+func (c *QuoteConverter) Convert(price TickerPrice, target Pair) (TickerPrice, error) {
+	pair, err := price.Ticker.Pair()
+	if err != nil {
+		return price, err
+	}
+	ratePair, invert, found := c.rate(pair.Quote, target.Quote)
+	// rate tickers aren't converted by themselves to avoid feedback loop
+	if pair.Base != target.Base || !found || c.rates[target] != nil {
+		return price, ErrNotConvertible
+	}
+
+	c.m.RLock()
+	rate := *c.rates[ratePair]
+	c.m.RUnlock()
+	if rate.price == "" {
+		return price, ErrNoConversionRate
+	}
+	age := c.timeNow().Sub(rate.time)
+	if c.maxAge > 0 && age > c.maxAge {
+		return price, ErrConversionRateOld
+	}
+	multiplier := rate.value
+	if invert {
+		multiplier = 1 / rate.value
+	}
+
+	if price.Book != nil {
+		book := *price.Book
+		book.Bids, book.Asks = scaleLevels(book.Bids, multiplier), scaleLevels(book.Asks, multiplier)
+		price.Book = &book
+	} else {
+		value, err := strconv.ParseFloat(price.Price, 64)
+		if err != nil {
+			return price, err
+		}
+		price.Price = strconv.FormatFloat(value*multiplier, 'f', -1, 64)
+	}
+	price.Conversion = &Conversion{From: pair.Ticker(), RateTicker: ratePair.Ticker(), Rate: rate.price, RateAge: age}
+	price.Ticker = target.Ticker()
+	return price, nil
+}
+
+// rate finds rate ticker between assets, invert is set if it's quoted in from asset
+func (c *QuoteConverter) rate(from, to string) (Pair, bool, bool) {
+	if pair := (Pair{Base: from, Quote: to}); c.rates[pair] != nil {
+		return pair, false, true
+	}
+	if pair := (Pair{Base: to, Quote: from}); c.rates[pair] != nil {
+		return pair, true, true
+	}
+	return Pair{}, false, false
+}
+
+func scaleLevels(levels []collector.Level, multiplier float64) []collector.Level {
+	result := make([]collector.Level, 0, len(levels))
+	for _, level := range levels {
+		result = append(result, collector.Level{Price: level.Price * multiplier, Size: level.Size})
+	}
+	return result
+}
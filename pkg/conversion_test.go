@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_QuoteConverter(t *testing.T) {
+	now := fixedTimeNow()
+	c := NewQuoteConverter(func() time.Time { return now }).WithRate("USDT_USD").WithMaxAge(time.Minute)
+	usd := NewPair("BTC", "USD")
+
+	_, err := c.Convert(TickerPrice{Ticker: "BTC_USDT", Price: "100"}, usd)
+	assert.Equal(t, ErrNoConversionRate, err)
+	_, err = c.Convert(TickerPrice{Ticker: "BTC_USDC", Price: "100"}, usd)
+	assert.Equal(t, ErrNotConvertible, err)
+	_, err = c.Convert(TickerPrice{Ticker: "USDT_USDT", Price: "1"}, NewPair("USDT", "USD"))
+	assert.Equal(t, ErrNotConvertible, err, "rate ticker isn't converted")
+
+	c.Update(FairPriceResult{TickerPrice: TickerPrice{Ticker: "USDT_USD", Price: "0.5", Time: now.Add(-time.Second)}, Status: StatusOK})
+	c.Update(FairPriceResult{TickerPrice: TickerPrice{Ticker: "USDT_USD", Price: collector.NoValue, Time: now}, Status: StatusNoValue})
+
+	price, err := c.Convert(TickerPrice{Ticker: "BTCUSDT", Price: "100", Source: "a"}, usd)
+	require.NoError(t, err)
+	assert.Equal(t, TickerPrice{
+		Ticker:     BTCUSDTicker,
+		Price:      "50",
+		Source:     "a",
+		Conversion: &Conversion{From: "BTC_USDT", RateTicker: "USDT_USD", Rate: "0.5", RateAge: time.Second},
+	}, price)
+
+	price, err = c.Convert(TickerPrice{Ticker: "BTC_USD", Book: &collector.Book{
+		Bids: []collector.Level{{Price: 100, Size: 1}},
+		Asks: []collector.Level{{Price: 101, Size: 2}},
+	}}, NewPair("BTC", "USDT"))
+	require.NoError(t, err, "rate is inverted")
+	assert.Equal(t, []collector.Level{{Price: 200, Size: 1}}, price.Book.Bids)
+	assert.Equal(t, []collector.Level{{Price: 202, Size: 2}}, price.Book.Asks)
+
+	now = now.Add(time.Hour)
+	_, err = c.Convert(TickerPrice{Ticker: "BTC_USDT", Price: "100"}, usd)
+	assert.Equal(t, ErrConversionRateOld, err)
+}
+
+func Test_Multiplexor_ConvertsPrices(t *testing.T) {
+	c := NewQuoteConverter(fixedTimeNow).WithRate("USDT_USD")
+	c.Update(FairPriceResult{TickerPrice: TickerPrice{Ticker: "USDT_USD", Price: "2", Time: fixedTimeNow()}})
+	var result []TickerPrice
+	for price := range NewMultiplexor().WithConverter(c).Subscribe(valuesToStreams([][]interface{}{{
+		&TickerPrice{Ticker: "BTC_USDT", Price: "3"},
+		&TickerPrice{Ticker: "ETH_USDT", Price: "4"},
+		"Disconnected",
+	}})) {
+		result = append(result, price)
+	}
+	require.Len(t, result, 1)
+	assert.Equal(t, "6", result[0].Price)
+	assert.Equal(t, BTCUSDTicker, result[0].Ticker)
+	require.NotNil(t, result[0].Conversion)
+}
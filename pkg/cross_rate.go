@@ -0,0 +1,121 @@
+package pkg
+
+import (
+	"strconv"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+)
+
+// Leg is an input of cross rate
+type Leg struct {
+	Ticker Ticker
+	Invert bool // 1/price is used, e.g. EUR_USD leg of USD_EUR
+}
+
+// CrossRate describes synthetic ticker, its price is product of prices of the legs,
+// e.g. ETH_EUR = ETH_USD * 1/EUR_USD
+type CrossRate struct {
+	Ticker    Ticker
+	Legs      []Leg
+	Precision int
+}
+
+type crossState struct {
+	rate    CrossRate
+	legs    map[Ticker]struct{}
+	pending map[Ticker]FairPriceResult // leg prices published since the last cross price
+}
+
+// CrossRates computes synthetic tickers from fair prices of other tickers.
+// Cross price is published as soon as every leg published its price of the next period.
+type CrossRates struct {
+	rates   []*crossState
+	timeNow timeNow
+}
+
+// NewCrossRates constructor
+func NewCrossRates(rates []CrossRate, tn timeNow) *CrossRates {
+	c := &CrossRates{timeNow: tn}
+	for _, rate := range rates {
+		state := &crossState{rate: rate, legs: map[Ticker]struct{}{}, pending: map[Ticker]FairPriceResult{}}
+		for _, leg := range rate.Legs {
+			state.legs[leg.Ticker] = struct{}{}
+		}
+		c.rates = append(c.rates, state)
+	}
+	return c
+}
+
+// Start forwards fair prices from in to out adding cross prices, it returns when in is closed.
+// Sends are blocking, so out must be read until Start returns.
+func (c *CrossRates) Start(in <-chan FairPriceResult, out chan<- FairPriceResult) {
+	for price := range in {
+		out <- price
+		for _, state := range c.rates {
+			if _, found := state.legs[price.Ticker]; !found {
+				continue
+			}
+			state.pending[price.Ticker] = price
+			if len(state.pending) < len(state.legs) {
+				continue
+			}
+			out <- c.cross(state.rate, state.pending)
+			state.pending = map[Ticker]FairPriceResult{}
+		}
+	}
+}
+
+// cross calculates price of the rate, its status is the worst status of the legs
+func (c *CrossRates) cross(rate CrossRate, legs map[Ticker]FairPriceResult) FairPriceResult {
+	result := FairPriceResult{
+		TickerPrice: TickerPrice{Ticker: rate.Ticker, Time: c.timeNow(), Price: collector.NoValue},
+		Status:      StatusOK,
+		SourceCount: -1,
+	}
+	value := 1.
+	for _, leg := range rate.Legs {
+		price := legs[leg.Ticker]
+		if statusRank(price.Status) > statusRank(result.Status) {
+			result.Status = price.Status
+		}
+		if result.PeriodStart.IsZero() || price.PeriodStart.Before(result.PeriodStart) {
+			result.PeriodStart = price.PeriodStart
+		}
+		if price.PeriodEnd.After(result.PeriodEnd) {
+			result.PeriodEnd = price.PeriodEnd
+		}
+		if result.SourceCount < 0 || price.SourceCount < result.SourceCount {
+			result.SourceCount = price.SourceCount
+		}
+		result.Partial = result.Partial || price.Partial
+
+		f, err := strconv.ParseFloat(price.Price, 64)
+		if err != nil || (leg.Invert && f == 0) {
+			result.Status = StatusNoValue
+			continue
+		}
+		if leg.Invert {
+			f = 1 / f
+		}
+		value *= f
+	}
+	if result.Status != StatusNoValue {
+		result.Price = strconv.FormatFloat(value, 'f', rate.Precision, 64)
+	}
+	return result
+}
+
+// statusRank orders statuses from the best to the worst
+func statusRank(status PriceStatus) int {
+	switch status {
+	case StatusOK:
+		return 0
+	case StatusDegraded:
+		return 1
+	case StatusFallback:
+		return 2
+	case StatusStale:
+		return 3
+	}
+	return 4
+}
@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CrossRates(t *testing.T) {
+	c := NewCrossRates([]CrossRate{{
+		Ticker:    "ETH_EUR",
+		Legs:      []Leg{{Ticker: "ETH_USD"}, {Ticker: "EUR_USD", Invert: true}},
+		Precision: 2,
+	}}, fixedTimeNow)
+	start := fixedTimeNow()
+	in, out := make(chan FairPriceResult, 10), make(chan FairPriceResult, 10)
+	in <- FairPriceResult{TickerPrice: TickerPrice{Ticker: "ETH_USD", Price: "2500"}, Status: StatusOK, SourceCount: 3, PeriodStart: start, PeriodEnd: start.Add(time.Second)}
+	in <- FairPriceResult{TickerPrice: TickerPrice{Ticker: "BTC_USD", Price: "40000"}, Status: StatusOK}
+	in <- FairPriceResult{TickerPrice: TickerPrice{Ticker: "EUR_USD", Price: "1.25"}, Status: StatusDegraded, SourceCount: 1, PeriodStart: start.Add(time.Millisecond), PeriodEnd: start.Add(time.Second)}
+	in <- FairPriceResult{TickerPrice: TickerPrice{Ticker: "EUR_USD", Price: "1.2"}, Status: StatusOK, SourceCount: 2}
+	in <- FairPriceResult{TickerPrice: TickerPrice{Ticker: "ETH_USD", Price: collector.NoValue}, Status: StatusNoValue}
+	close(in)
+	c.Start(in, out)
+	close(out)
+
+	var cross []FairPriceResult
+	inputs := 0
+	for price := range out {
+		if price.Ticker == "ETH_EUR" {
+			cross = append(cross, price)
+		} else {
+			inputs++
+		}
+	}
+	assert.Equal(t, 5, inputs, "inputs are forwarded")
+	require.Len(t, cross, 2)
+	assert.Equal(t, FairPriceResult{
+		TickerPrice: TickerPrice{Ticker: "ETH_EUR", Time: fixedTimeNow(), Price: "2000.00"},
+		Status:      StatusDegraded,
+		PeriodStart: start,
+		PeriodEnd:   start.Add(time.Second),
+		SourceCount: 1,
+	}, cross[0])
+	assert.Equal(t, collector.NoValue, cross[1].Price)
+	assert.Equal(t, StatusNoValue, cross[1].Status)
+}
@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+type dedupEntry struct {
+	key  string
+	seen time.Time
+}
+
+// deduplicator remembers prints seen within the window, oldest ones are forgotten first
+// when the window passes or the number of remembered prints exceeds the limit
+type deduplicator struct {
+	m sync.Mutex
+
+	window     time.Duration
+	maxEntries int
+	seen       map[string]struct{}
+	queue      []dedupEntry // in order of arrival
+}
+
+func newDeduplicator(window time.Duration, maxEntries int) *deduplicator {
+	return &deduplicator{
+		window:     window,
+		maxEntries: maxEntries,
+		seen:       map[string]struct{}{},
+	}
+}
+
+// duplicate reports whether the print was already seen and remembers it otherwise.
+// Prints are identified within their source by trade ID, or by price, event time and size if there is no ID,
+// so equal prints of different sources are never duplicates. Quote events are never duplicates either.
+func (d *deduplicator) duplicate(price TickerPrice, now time.Time) bool {
+	if price.Book != nil {
+		return false
+	}
+	key := price.Source + "|id|" + price.TradeID
+	if price.TradeID == "" {
+		key = price.Source + "|print|" + price.Price + "|" + strconv.FormatInt(price.Time.UnixNano(), 10) + "|" + price.Size
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.expire(now)
+	if _, found := d.seen[key]; found {
+		return true
+	}
+	d.seen[key] = struct{}{}
+	d.queue = append(d.queue, dedupEntry{key: key, seen: now})
+	if d.maxEntries > 0 && len(d.queue) > d.maxEntries {
+		d.forget(1)
+	}
+	return false
+}
+
+func (d *deduplicator) expire(now time.Time) {
+	n := 0
+	for n < len(d.queue) && now.Sub(d.queue[n].seen) > d.window {
+		n++
+	}
+	d.forget(n)
+}
+
+func (d *deduplicator) forget(n int) {
+	for _, entry := range d.queue[:n] {
+		delete(d.seen, entry.key)
+	}
+	d.queue = d.queue[n:]
+}
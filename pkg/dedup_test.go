@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Deduplicator(t *testing.T) {
+	d := newDeduplicator(time.Minute, 2)
+	now := fixedTimeNow()
+
+	withID := TickerPrice{Source: "a", TradeID: "1", Price: "1", Time: now}
+	assert.False(t, d.duplicate(withID, now))
+	assert.True(t, d.duplicate(withID, now), "resent trade")
+	other := withID
+	other.Source = "b"
+	assert.False(t, d.duplicate(other, now), "trade IDs are unique within the source only")
+
+	// window is passed
+	assert.False(t, d.duplicate(withID, now.Add(2*time.Minute)))
+
+	trade := TickerPrice{Source: "a", Price: "2", Size: "1", Time: now}
+	assert.False(t, d.duplicate(trade, now))
+	assert.True(t, d.duplicate(trade, now), "prints without ID are compared by price, time and size")
+	trade.Source = "b"
+	assert.False(t, d.duplicate(trade, now), "equal prints of different sources aren't duplicates")
+
+	book := TickerPrice{Book: &collector.Book{}}
+	assert.False(t, d.duplicate(book, now))
+	assert.False(t, d.duplicate(book, now))
+}
+
+func Test_Deduplicator_MaxEntries(t *testing.T) {
+	d := newDeduplicator(time.Hour, 2)
+	now := fixedTimeNow()
+	for _, id := range []string{"1", "2", "3"} {
+		assert.False(t, d.duplicate(TickerPrice{Source: "a", TradeID: id}, now))
+	}
+	assert.Len(t, d.seen, 2)
+	assert.False(t, d.duplicate(TickerPrice{Source: "a", TradeID: "1"}, now), "the oldest print is forgotten")
+	assert.True(t, d.duplicate(TickerPrice{Source: "a", TradeID: "3"}, now))
+}
+
+func Test_Multiplexor_DropsDuplicates(t *testing.T) {
+	m := NewMultiplexor().WithDedup(time.Minute, 0)
+	var result []string
+	for price := range m.Subscribe(valuesToStreams([][]interface{}{{
+		&TickerPrice{TradeID: "1", Price: "1"},
+		&TickerPrice{TradeID: "2", Price: "2"},
+		&TickerPrice{TradeID: "1", Price: "1"},
+		"Disconnected",
+	}})) {
+		result = append(result, price.Price)
+	}
+	assert.Equal(t, []string{"1", "2"}, result)
+	require.Equal(t, map[string]int{"source-0": 1}, m.Duplicates())
+}
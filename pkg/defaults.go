@@ -1,6 +1,11 @@
 package pkg
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+)
 
 type Ticker string
 
@@ -8,12 +13,57 @@ const (
 	BTCUSDTicker Ticker = "BTC_USD"
 )
 
+type PriceStatus string
+
+const (
+	StatusOK       PriceStatus = "ok"
+	StatusNoValue  PriceStatus = "no_value"
+	StatusDegraded PriceStatus = "degraded" // fewer sources than required
+	StatusStale    PriceStatus = "stale"    // no value within the period, the last one is carried forward
+	StatusFallback PriceStatus = "fallback" // no value within the period, fallback collector is used
+)
+
 type TickerPrice struct {
-	Ticker Ticker
-	Time   time.Time
-	Price  string // decimal value. example: "0", "10", "12.2", "13.2345122"
+	Ticker  Ticker
+	Time    time.Time
+	Price   string          // decimal value. example: "0", "10", "12.2", "13.2345122"
+	Source  string          // name of the source the price came from, set by Multiplexor if empty
+	Size    string          // decimal trade size, optional
+	TradeID string          // unique within the source, optional. Used for deduplication.
+	Seq     uint64          // per-source sequence number starting from 1, optional. Used for gap detection.
+	Book    *collector.Book // quote event: order book snapshot instead of trade, Price is empty
+	// set if the price was converted from another quote asset, Price and Book are converted ones
+	Conversion *Conversion
+}
+
+// FairPriceResult is a fair price of the period published by FairPrice.
+// Only Ticker, Time and Price of the embedded price are set, Price is collector.NoValue if there is no value.
+type FairPriceResult struct {
+	TickerPrice
+	Status      PriceStatus
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	SourceCount int    // number of distinct sources which contributed to the period
+	Partial     bool   // period was cut short by shutdown
+	Group       string // active source group of failover, empty if failover isn't used
+	Candle      *collector.Candle
+	Impact      *collector.Impact
 }
 
 type IPriceStreamSubscriber interface {
 	SubscribePriceStream(Ticker) (chan TickerPrice, chan error)
 }
+
+// ISubscription is a handle of a single price stream subscription
+type ISubscription interface {
+	Prices() <-chan TickerPrice
+	Errors() <-chan error
+	// Close stops the stream and releases its resources. Safe to call several times.
+	Close()
+}
+
+// IPriceStreamSubscriberV2 is a context-aware version of IPriceStreamSubscriber.
+// Stream must be stopped either when ctx is done or when subscription is closed.
+type IPriceStreamSubscriberV2 interface {
+	SubscribePriceStreamContext(ctx context.Context, ticker Ticker) (ISubscription, error)
+}
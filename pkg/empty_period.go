@@ -0,0 +1,15 @@
+package pkg
+
+// EmptyPeriodPolicy defines what is published when collector has no value for a period.
+// Fallback is tried first, then carry-forward, "no value" is published if neither helps.
+type EmptyPeriodPolicy struct {
+	// CarryForward publishes the last value marked as stale
+	CarryForward bool
+	// MaxPeriods limits number of periods in a row the value is carried forward, 0 means no limit
+	MaxPeriods int
+	// Fallback is a secondary collector, its value is used when the primary one has nothing
+	Fallback IFairPriceCollector
+	// FallbackSources is a secondary source group: their prices go to Fallback only.
+	// If empty, Fallback receives prices of all sources.
+	FallbackSources []string
+}
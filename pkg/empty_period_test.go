@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func priceOf(source, price string) TickerPrice {
+	return TickerPrice{Source: source, Price: price, Time: fixedTimeNow().Add(time.Hour)}
+}
+
+func statuses(results ...FairPriceResult) (result [][2]string) {
+	for _, r := range results {
+		result = append(result, [2]string{r.Price, string(r.Status)})
+	}
+	return result
+}
+
+func Test_EmptyPeriod_NoPolicy(t *testing.T) {
+	p := NewFairPrice(collector.NewLatest(3), fixedTimeNow)
+	p.collect(priceOf("a", "1.0"))
+	assert.Equal(t, [][2]string{
+		{"1.000", "ok"},
+		{"no value", "no_value"},
+	}, statuses(p.result(tn), p.result(tn)))
+}
+
+func Test_EmptyPeriod_CarryForwardLimited(t *testing.T) {
+	p := NewFairPrice(collector.NewLatest(3), fixedTimeNow).
+		WithEmptyPeriodPolicy(EmptyPeriodPolicy{CarryForward: true, MaxPeriods: 2})
+	first := p.result(tn) // nothing to carry yet
+	p.collect(priceOf("a", "1.0"))
+	second := p.result(tn)
+	third, fourth, fifth := p.result(tn), p.result(tn), p.result(tn)
+	p.collect(priceOf("a", "2.0"))
+	sixth, seventh := p.result(tn), p.result(tn)
+
+	assert.Equal(t, [][2]string{
+		{"no value", "no_value"},
+		{"1.000", "ok"},
+		{"1.000", "stale"},
+		{"1.000", "stale"},
+		{"no value", "no_value"},
+		{"2.000", "ok"},
+		{"2.000", "stale"},
+	}, statuses(first, second, third, fourth, fifth, sixth, seventh))
+}
+
+func Test_EmptyPeriod_FallbackSourceGroup(t *testing.T) {
+	p := NewFairPrice(collector.NewLatest(3), fixedTimeNow).
+		WithEmptyPeriodPolicy(EmptyPeriodPolicy{
+			Fallback:        collector.NewAverage(3),
+			FallbackSources: []string{"b", "c"},
+		})
+	p.collect(priceOf("a", "1.0"))
+	p.collect(priceOf("b", "5.0"))
+	first := p.result(tn)
+	p.collect(priceOf("b", "2.0"))
+	p.collect(priceOf("c", "3.0"))
+	second := p.result(tn)
+
+	assert.Equal(t, [][2]string{
+		{"1.000", "ok"},
+		{"2.500", "fallback"},
+	}, statuses(first, second))
+	assert.Equal(t, 1, first.SourceCount)
+	assert.Equal(t, 2, second.SourceCount)
+}
@@ -0,0 +1,147 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+)
+
+// SourceGroup is a named set of sources used by failover
+type SourceGroup struct {
+	Name    string
+	Sources []string
+	// Collector of the group prices, main collector of FairPrice is used for the primary group
+	Collector IFairPriceCollector
+}
+
+// FailoverPolicy switches fair price between source groups. The active group is used while it meets quorum,
+// otherwise fair price fails over to the group of highest priority which meets it. The active group is switched
+// back to a group of higher priority as soon as that one meets quorum for RecoverAfter periods in a row.
+// Prices of sources out of groups are ignored.
+type FailoverPolicy struct {
+	Groups       []SourceGroup // in order of priority, the first one is primary
+	FailAfter    int           // periods in a row the active group misses quorum before failing over, 1 if 0
+	RecoverAfter int           // periods in a row a group of higher priority meets quorum before switching back, 1 if 0
+}
+
+// Validate checks the policy is usable: there is a primary group, backup groups have collectors
+// and each source belongs to a single group
+func (p FailoverPolicy) Validate() error {
+	if len(p.Groups) == 0 {
+		return errors.New("primary group is required")
+	}
+	if p.FailAfter < 0 || p.RecoverAfter < 0 {
+		return errors.New("fail after and recover after must not be negative")
+	}
+	grouped := map[string]string{}
+	for i, group := range p.Groups {
+		if i > 0 && group.Collector == nil {
+			return fmt.Errorf("collector of backup group %q is required", group.Name)
+		}
+		for _, source := range group.Sources {
+			if other, found := grouped[source]; found {
+				return fmt.Errorf("source %q is in groups %q and %q", source, other, group.Name)
+			}
+			grouped[source] = group.Name
+		}
+	}
+	return nil
+}
+
+// failover keeps state of FailoverPolicy
+type failover struct {
+	policy     FailoverPolicy
+	groups     map[string]int        // group index by source
+	sources    []map[string]struct{} // sources contributed to the current period by group
+	active     int
+	failing    int // periods in a row the active group misses quorum
+	recovering int // periods in a row a group of higher priority meets quorum
+}
+
+func newFailover(policy FailoverPolicy) *failover {
+	if policy.FailAfter < 1 {
+		policy.FailAfter = 1
+	}
+	if policy.RecoverAfter < 1 {
+		policy.RecoverAfter = 1
+	}
+	f := &failover{
+		policy:  policy,
+		groups:  map[string]int{},
+		sources: make([]map[string]struct{}, len(policy.Groups)),
+	}
+	for i, group := range policy.Groups {
+		f.sources[i] = map[string]struct{}{}
+		for _, source := range group.Sources {
+			f.groups[source] = i
+		}
+	}
+	return f
+}
+
+// group returns index of the source group, false if the source is out of groups
+func (f *failover) group(source string) (int, bool) {
+	i, found := f.groups[source]
+	return i, found
+}
+
+// collect puts price into collector of backup group
+func (f *failover) collect(group int, price TickerPrice) error {
+	if err := collectInto(f.policy.Groups[group].Collector, price); err != nil {
+		return err
+	}
+	f.sources[group][price.Source] = struct{}{}
+	return nil
+}
+
+// result takes prices of backup groups, switches the active group and returns result of the active one.
+// Primary result is taken from the main collector.
+func (f *failover) result(primary FairPriceResult, quorum int) FairPriceResult {
+	if quorum < 1 {
+		quorum = 1
+	}
+	results := make([]FairPriceResult, len(f.policy.Groups))
+	meets := make([]bool, len(f.policy.Groups))
+	for i, group := range f.policy.Groups {
+		results[i] = primary
+		if i > 0 {
+			results[i].SourceCount, results[i].Candle, results[i].Impact = len(f.sources[i]), nil, nil
+			takePrice(group.Collector, &results[i])
+			f.sources[i] = map[string]struct{}{}
+		}
+		meets[i] = results[i].Price != collector.NoValue && results[i].SourceCount >= quorum
+	}
+	f.update(meets)
+
+	result := results[f.active]
+	result.Group = f.policy.Groups[f.active].Name
+	return result
+}
+
+func (f *failover) update(meets []bool) {
+	best := -1
+	for i, ok := range meets {
+		if ok {
+			best = i
+			break
+		}
+	}
+	if meets[f.active] {
+		f.failing = 0
+	} else {
+		f.failing++
+	}
+	if best >= 0 && best < f.active {
+		f.recovering++
+	} else {
+		f.recovering = 0
+	}
+	switch {
+	case best >= 0 && best < f.active && f.recovering >= f.policy.RecoverAfter:
+	case best >= 0 && !meets[f.active] && f.failing >= f.policy.FailAfter:
+	default:
+		return
+	}
+	f.active, f.failing, f.recovering = best, 0, 0
+}
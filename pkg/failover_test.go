@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Failover_Hysteresis(t *testing.T) {
+	p := NewFairPrice(collector.NewAverage(3), fixedTimeNow).
+		WithQuorum(2).
+		WithFailover(FailoverPolicy{
+			Groups: []SourceGroup{
+				{Name: "primary", Sources: []string{"a", "b"}},
+				{Name: "backup", Sources: []string{"c", "d"}, Collector: collector.NewAverage(3)},
+			},
+			FailAfter:    2,
+			RecoverAfter: 2,
+		})
+	period := func(sources ...string) FairPriceResult {
+		values := map[string]string{"a": "1", "b": "3", "c": "10", "d": "20", "z": "100"}
+		for _, source := range sources {
+			p.collect(priceOf(source, values[source]))
+		}
+		return p.result(tn)
+	}
+	results := []FairPriceResult{
+		period("a", "b", "c", "d", "z"),
+		period("a", "c", "d"),
+		period("a", "c", "d"),
+		period("a", "b", "c", "d"),
+		period("a", "b", "c", "d"),
+	}
+
+	assert.Equal(t, [][2]string{
+		{"2.000", "ok"},
+		{"1.000", "degraded"},
+		{"15.000", "ok"},
+		{"15.000", "ok"},
+		{"2.000", "ok"},
+	}, statuses(results...))
+	var groups []string
+	for _, r := range results {
+		groups = append(groups, r.Group)
+	}
+	assert.Equal(t, []string{"primary", "primary", "backup", "backup", "primary"}, groups)
+	assert.Equal(t, 2, results[2].SourceCount)
+}
+
+func Test_Failover_StaysWithoutBetterGroup(t *testing.T) {
+	f := newFailover(FailoverPolicy{Groups: []SourceGroup{{Name: "primary"}, {Name: "backup"}, {Name: "last"}}})
+	f.update([]bool{false, false, false})
+	assert.Equal(t, 0, f.active, "nothing meets quorum")
+	f.update([]bool{false, false, true})
+	assert.Equal(t, 2, f.active)
+	f.update([]bool{false, true, false})
+	assert.Equal(t, 1, f.active, "failed group is left for the best one")
+}
+
+func Test_FailoverPolicy_Validate(t *testing.T) {
+	assert.Error(t, FailoverPolicy{}.Validate())
+	assert.Error(t, FailoverPolicy{Groups: []SourceGroup{{Name: "primary"}, {Name: "backup"}}}.Validate(),
+		"backup collector is required")
+	assert.Error(t, FailoverPolicy{Groups: []SourceGroup{{Name: "primary"}}, FailAfter: -1}.Validate())
+	assert.Error(t, FailoverPolicy{Groups: []SourceGroup{
+		{Name: "primary", Sources: []string{"a"}},
+		{Name: "backup", Sources: []string{"a"}, Collector: collector.NewLatest(3)},
+	}}.Validate())
+
+	assert.PanicsWithValue(t, "pkg: invalid failover policy: primary group is required", func() {
+		NewFairPrice(collector.NewLatest(3), fixedTimeNow).WithFailover(FailoverPolicy{})
+	})
+}
@@ -2,7 +2,11 @@ package pkg
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
 )
 
 type IFairPriceCollector interface {
@@ -10,11 +14,46 @@ type IFairPriceCollector interface {
 	GetFairPriceAndReset() string
 }
 
+// ITradeCollector is implemented by collectors which need price details, it's used instead of Collect
+type ITradeCollector interface {
+	CollectTrade(collector.Trade) error
+}
+
+// IQuoteCollector is implemented by collectors which use order book snapshots, quote events are ignored by others
+type IQuoteCollector interface {
+	CollectBook(collector.Book) error
+}
+
+// IImpactCollector is implemented by collectors which produce impact prices, impact mid is used as fair price
+type IImpactCollector interface {
+	GetImpactAndReset() (collector.Impact, bool)
+}
+
+// ICandleCollector is implemented by collectors which build OHLC candles
+type ICandleCollector interface {
+	GetCandleAndReset() (collector.Candle, bool)
+}
+
 type timeNow func() time.Time
 
 type FairPrice struct {
 	collector IFairPriceCollector
 	timeNow   timeNow
+	ticker    Ticker
+	quorum    int
+	metrics   *Metrics
+	flush     bool
+	empty     EmptyPeriodPolicy
+	secondary map[string]struct{} // sources of fallback group
+	failover  *failover
+
+	lastPrice string // latest published fresh value kept for carry-forward, empty if none
+	carried   int    // number of periods the last value was carried forward
+
+	collected  int                 // number of prices collected within the current period
+	sources    map[string]struct{} // sources contributed to the current period
+	fallbacks  map[string]struct{} // sources contributed to fallback collector within the current period
+	eventTimes []time.Time         // times of prices collected within the current period, kept for metrics only
 }
 
 // NewFairPrice constructor
@@ -22,14 +61,62 @@ func NewFairPrice(collector IFairPriceCollector, tn timeNow) *FairPrice {
 	return &FairPrice{
 		collector: collector,
 		timeNow:   tn,
+		ticker:    BTCUSDTicker,
+		sources:   map[string]struct{}{},
+		fallbacks: map[string]struct{}{},
+	}
+}
+
+// WithTicker sets ticker of output prices
+func (p *FairPrice) WithTicker(ticker Ticker) *FairPrice {
+	p.ticker = ticker
+	return p
+}
+
+// WithQuorum sets minimal number of sources per period, prices of periods with less sources are marked as degraded
+func (p *FairPrice) WithQuorum(quorum int) *FairPrice {
+	p.quorum = quorum
+	return p
+}
+
+// WithMetrics enables instrumentation
+func (p *FairPrice) WithMetrics(metrics *Metrics) *FairPrice {
+	p.metrics = metrics
+	return p
+}
+
+// WithFinalFlush makes Start publish the last partial period when it's stopped.
+// Final price is sent with blocking operation, so output must be read until Start returns.
+func (p *FairPrice) WithFinalFlush() *FairPrice {
+	p.flush = true
+	return p
+}
+
+// WithEmptyPeriodPolicy sets what is published for periods without value
+func (p *FairPrice) WithEmptyPeriodPolicy(policy EmptyPeriodPolicy) *FairPrice {
+	p.empty = policy
+	p.secondary = map[string]struct{}{}
+	for _, source := range policy.FallbackSources {
+		p.secondary[source] = struct{}{}
 	}
+	return p
+}
+
+// WithFailover makes fair price switch between primary and backup source groups depending on quorum,
+// the active group is reported in Group of each output. It panics if the policy is invalid, see FailoverPolicy.Validate.
+func (p *FairPrice) WithFailover(policy FailoverPolicy) *FairPrice {
+	if err := policy.Validate(); err != nil {
+		panic(fmt.Sprintf("pkg: invalid failover policy: %v", err))
+	}
+	p.failover = newFailover(policy)
+	return p
 }
 
 func (p *FairPrice) Start(
 	ctx context.Context,
 	stream <-chan TickerPrice,
 	d time.Duration,
-	output chan<- TickerPrice,
+	output chan<- FairPriceResult,
 ) {
 	ticker := time.NewTicker(d)
 	defer ticker.Stop()
@@ -40,25 +127,152 @@ func (p *FairPrice) Start(
 		//
 		select {
 		case <-ctx.Done():
+			p.flushPartial(startedTime, output)
 			return
 		case price, opened := <-stream:
 			if !opened {
+				p.flushPartial(startedTime, output)
 				return
 			}
 			// check price is valid
 			if price.Time.Before(startedTime) {
+				p.metrics.priceTooOld(p.ticker)
 				continue
 			}
-			_ = p.collector.Collect(price.Price, price.Time) // it's safe not to process an error, but it could be logged if required
+			p.collect(price)
 		case <-ticker.C:
-			fairPrice := p.collector.GetFairPriceAndReset()
+			fairPrice := p.result(startedTime)
 			select {
-			case output <- TickerPrice{Price: fairPrice, Time: p.timeNow()}:
+			case output <- fairPrice:
 			default:
 				// non-blocking operation
+				p.metrics.outputDropped(p.ticker)
 			}
-			// output <- TickerPrice{Price: fairPrice, Time: p.timeNow()}
-			startedTime = p.timeNow()
+			startedTime = fairPrice.PeriodEnd
+		}
+	}
+}
+
+func (p *FairPrice) collect(price TickerPrice) {
+	_, secondary := p.secondary[price.Source]
+	if p.empty.Fallback != nil && (secondary || len(p.secondary) == 0) {
+		if collectInto(p.empty.Fallback, price) == nil {
+			p.fallbacks[price.Source] = struct{}{}
+		}
+	}
+	if secondary {
+		p.collected++
+		return
+	}
+	if p.failover != nil {
+		group, found := p.failover.group(price.Source)
+		if !found {
+			return
+		}
+		if group > 0 {
+			if p.failover.collect(group, price) == nil {
+				p.collected++
+			}
+			return
+		}
+	}
+	if err := collectInto(p.collector, price); errors.Is(err, collector.ErrNotCollected) {
+		return
+	} else if err != nil {
+		p.metrics.parseError(p.ticker)
+		return // it's safe not to process an error, but it could be logged if required
+	}
+	p.collected++
+	p.sources[price.Source] = struct{}{}
+	if p.metrics != nil {
+		p.eventTimes = append(p.eventTimes, price.Time)
+	}
+}
+
+// result takes fair price of the period and resets its state
+func (p *FairPrice) result(startedTime time.Time) FairPriceResult {
+	now := p.timeNow()
+	result := FairPriceResult{
+		TickerPrice: TickerPrice{Ticker: p.ticker, Time: now},
+		Status:      StatusOK,
+		PeriodStart: startedTime,
+		PeriodEnd:   now,
+		SourceCount: len(p.sources),
+	}
+	takePrice(p.collector, &result)
+	if p.failover != nil {
+		result = p.failover.result(result, p.quorum)
+	}
+	fallback := FairPriceResult{TickerPrice: TickerPrice{Price: collector.NoValue}, SourceCount: len(p.fallbacks)}
+	if p.empty.Fallback != nil {
+		takePrice(p.empty.Fallback, &fallback)
+	}
+
+	p.collected = 0
+	p.sources = map[string]struct{}{}
+	p.fallbacks = map[string]struct{}{}
+	p.metrics.published(p.ticker, p.eventTimes, now)
+	p.eventTimes = nil
+
+	switch {
+	case result.Price != collector.NoValue && result.SourceCount < p.quorum:
+		result.Status = StatusDegraded
+	case result.Price != collector.NoValue:
+	case fallback.Price != collector.NoValue:
+		result.Price, result.Candle, result.Impact = fallback.Price, fallback.Candle, fallback.Impact
+		result.SourceCount, result.Status = fallback.SourceCount, StatusFallback
+	case p.empty.CarryForward && p.lastPrice != "" &&
+		(p.empty.MaxPeriods == 0 || p.carried < p.empty.MaxPeriods):
+		p.carried++
+		result.Price, result.Status = p.lastPrice, StatusStale
+		return result
+	default:
+		result.Status = StatusNoValue
+		return result
+	}
+	p.lastPrice, p.carried = result.Price, 0
+	return result
+}
+
+// flushPartial publishes current period if final flush is enabled and there is anything to publish
+func (p *FairPrice) flushPartial(startedTime time.Time, output chan<- FairPriceResult) {
+	if !p.flush || p.collected == 0 {
+		return
+	}
+	fairPrice := p.result(startedTime)
+	fairPrice.Partial = true
+	output <- fairPrice
+}
+
+func collectInto(c IFairPriceCollector, price TickerPrice) error {
+	if price.Book != nil {
+		qc, ok := c.(IQuoteCollector)
+		if !ok {
+			return collector.ErrNotCollected
+		}
+		book := *price.Book
+		book.Source = price.Source
+		return qc.CollectBook(book)
+	}
+	if tc, ok := c.(ITradeCollector); ok {
+		return tc.CollectTrade(collector.Trade{Source: price.Source, Price: price.Price, Size: price.Size, Time: price.Time})
+	}
+	return c.Collect(price.Price, price.Time)
+}
+
+// takePrice takes fair price of the period with its details and resets collector
+func takePrice(c IFairPriceCollector, result *FairPriceResult) {
+	result.Price = collector.NoValue
+	switch typed := c.(type) {
+	case ICandleCollector:
+		if candle, ok := typed.GetCandleAndReset(); ok {
+			result.Price, result.Candle = candle.Close, &candle
+		}
+	case IImpactCollector:
+		if impact, ok := typed.GetImpactAndReset(); ok {
+			result.Price, result.Impact = impact.Mid, &impact
 		}
+	default:
+		result.Price = c.GetFairPriceAndReset()
 	}
 }
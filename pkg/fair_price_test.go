@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startFairPrice(ctx context.Context, p *FairPrice, stream <-chan TickerPrice, d time.Duration) []FairPriceResult {
+	result := []FairPriceResult{}
+	output, wait := make(chan FairPriceResult, 1), make(chan struct{})
+	go func() {
+		for price := range output {
+			result = append(result, price)
+		}
+		close(wait)
+	}()
+	p.Start(ctx, stream, d, output)
+	close(output)
+	<-wait
+	return result
+}
+
+func Test_FinalFlush_StreamClosed_ExpectPartialPeriod(t *testing.T) {
+	m := NewMultiplexor()
+	resultCh := m.Subscribe(valuesToStreams([][]interface{}{{
+		&TickerPrice{Time: fixedTimeNow().Add(time.Hour), Price: "1.0"},
+		&TickerPrice{Time: fixedTimeNow().Add(time.Hour), Price: "2.0"},
+		"Disconnected",
+	}}))
+	p := NewFairPrice(collector.NewAverage(3), fixedTimeNow).WithFinalFlush()
+
+	result := startFairPrice(context.Background(), p, resultCh, time.Hour)
+	require.Len(t, result, 1)
+	assert.Equal(t, "1.500", result[0].Price)
+	assert.True(t, result[0].Partial)
+	assert.Equal(t, 1, result[0].SourceCount)
+}
+
+func Test_FinalFlush_Cancelled_ExpectPartialPeriod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := make(chan TickerPrice, 1)
+	stream <- TickerPrice{Time: fixedTimeNow().Add(time.Hour), Price: "1.0"}
+	go func() {
+		stream <- TickerPrice{Time: fixedTimeNow().Add(time.Hour), Price: "2.0"} // first price is collected
+		cancel()
+	}()
+	p := NewFairPrice(collector.NewLatest(3), fixedTimeNow).WithFinalFlush()
+
+	result := startFairPrice(ctx, p, stream, time.Hour)
+	require.Len(t, result, 1)
+	assert.True(t, result[0].Partial)
+}
+
+func Test_NoFinalFlush_ExpectNothing(t *testing.T) {
+	m := NewMultiplexor()
+	resultCh := m.Subscribe(valuesToStreams([][]interface{}{{
+		&TickerPrice{Time: fixedTimeNow().Add(time.Hour), Price: "1.0"},
+		"Disconnected",
+	}}))
+	p := NewFairPrice(collector.NewAverage(3), fixedTimeNow)
+
+	assert.Empty(t, startFairPrice(context.Background(), p, resultCh, time.Hour))
+}
+
+func Test_OHLC_OrderedByEventTime(t *testing.T) {
+	m := NewMultiplexor()
+	resultCh := m.Subscribe(valuesToStreams([][]interface{}{{
+		&TickerPrice{Time: fixedTimeNow().Add(2 * time.Hour), Price: "3.0", Size: "1"},
+		&TickerPrice{Time: fixedTimeNow().Add(time.Hour), Price: "2.0", Size: "0.5"},
+		&TickerPrice{Time: fixedTimeNow().Add(4 * time.Hour), Price: "1.0"},
+		&TickerPrice{Time: fixedTimeNow().Add(3 * time.Hour), Price: "4.0", Size: "2"},
+		"Disconnected",
+	}}))
+	p := NewFairPrice(collector.NewOHLC(1), fixedTimeNow).WithFinalFlush()
+
+	result := startFairPrice(context.Background(), p, resultCh, time.Hour)
+	require.Len(t, result, 1)
+	assert.Equal(t, "1.0", result[0].Price)
+	assert.Equal(t, &collector.Candle{
+		Open:      "2.0",
+		High:      "4.0",
+		Low:       "1.0",
+		Close:     "1.0",
+		Ticks:     4,
+		Volume:    "3.5",
+		OpenTime:  fixedTimeNow().Add(time.Hour),
+		CloseTime: fixedTimeNow().Add(4 * time.Hour),
+	}, result[0].Candle)
+}
+
+func Test_QuoteEvents(t *testing.T) {
+	book := func(bid, ask float64) *collector.Book {
+		return &collector.Book{Bids: []collector.Level{{Price: bid, Size: 1}}, Asks: []collector.Level{{Price: ask, Size: 1}}}
+	}
+	m := NewMultiplexor()
+	streams := func() []IPriceStreamSubscriber {
+		return valuesToStreams([][]interface{}{
+			{
+				&TickerPrice{Time: fixedTimeNow().Add(time.Hour), Price: "1.0"},
+				&TickerPrice{Time: fixedTimeNow().Add(time.Hour), Book: book(10, 12)},
+				"Disconnected",
+			},
+			{
+				&TickerPrice{Time: fixedTimeNow().Add(time.Hour), Book: book(12, 16)},
+				"Disconnected",
+			},
+		})
+	}
+	p := NewFairPrice(collector.NewMid(1), fixedTimeNow).WithFinalFlush().WithQuorum(2)
+	result := startFairPrice(context.Background(), p, m.Subscribe(streams()), time.Hour)
+	require.Len(t, result, 1)
+	assert.Equal(t, "12.5", result[0].Price, "median of mids of both sources")
+	assert.Equal(t, StatusOK, result[0].Status)
+
+	p = NewFairPrice(collector.NewAverage(1), fixedTimeNow).WithFinalFlush()
+	result = startFairPrice(context.Background(), p, NewMultiplexor().Subscribe(streams()), time.Hour)
+	require.Len(t, result, 1)
+	assert.Equal(t, "1.0", result[0].Price, "quote events are ignored by trade collectors")
+	assert.Equal(t, 1, result[0].SourceCount)
+}
+
+func Test_IgnoredTrades_NotContributions(t *testing.T) {
+	p := NewFairPrice(collector.NewMid(1), fixedTimeNow).WithFinalFlush()
+	p.collect(priceOf("a", "1.0"))
+	assert.Equal(t, 0, p.collected)
+	quote := priceOf("b", "")
+	quote.Book = &collector.Book{Bids: []collector.Level{{Price: 10, Size: 1}}, Asks: []collector.Level{{Price: 12, Size: 1}}}
+	p.collect(quote)
+	crossed := priceOf("c", "")
+	crossed.Book = &collector.Book{Bids: []collector.Level{{Price: 13, Size: 1}}, Asks: []collector.Level{{Price: 12, Size: 1}}}
+	p.collect(crossed)
+	result := p.result(tn)
+	assert.Equal(t, "11.0", result.Price)
+	assert.Equal(t, 1, result.SourceCount, "trade-only source and source of crossed book aren't counted")
+
+	output := make(chan FairPriceResult, 1)
+	p.collect(priceOf("a", "2.0"))
+	p.flushPartial(tn, output)
+	assert.Len(t, output, 0, "nothing to flush")
+}
+
+func Test_UnweightedSource_NotContribution(t *testing.T) {
+	c := collector.NewSourceLatest(1, collector.WeightedMean()).WithWeights(map[string]float64{"a": 1})
+	p := NewFairPrice(c, fixedTimeNow).WithQuorum(2)
+	p.collect(priceOf("a", "1.0"))
+	p.collect(priceOf("b", "5.0"))
+	result := p.result(tn)
+	assert.Equal(t, "1.0", result.Price)
+	assert.Equal(t, 1, result.SourceCount, "source without weight isn't counted")
+	assert.Equal(t, StatusDegraded, result.Status)
+}
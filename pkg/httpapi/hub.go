@@ -0,0 +1,261 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+)
+
+const (
+	DefaultClientBufferSize = 16
+
+	heartbeatPeriod = 15 * time.Second
+)
+
+type client struct {
+	filter  map[pkg.Ticker]struct{} // nil means all tickers
+	send    chan PriceView
+	evicted chan struct{} // closed when client was evicted as too slow
+}
+
+func (c *client) accepts(ticker pkg.Ticker) bool {
+	if c.filter == nil {
+		return true
+	}
+	_, found := c.filter[ticker]
+	return found
+}
+
+// Hub fans out fair prices to streaming clients.
+// Clients which don't keep up are evicted, so a lagging client never blocks publishing.
+type Hub struct {
+	m sync.Mutex
+
+	clients    map[*client]struct{}
+	latest     map[pkg.Ticker]pkg.FairPriceResult
+	bufferSize int
+	evictions  int
+}
+
+// NewHub constructor. bufferSize is the number of prices a client could lag behind before eviction.
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultClientBufferSize
+	}
+	return &Hub{
+		clients:    map[*client]struct{}{},
+		latest:     map[pkg.Ticker]pkg.FairPriceResult{},
+		bufferSize: bufferSize,
+	}
+}
+
+// Publish sends fair price to all interested clients without blocking
+func (h *Hub) Publish(price pkg.FairPriceResult) {
+	view := NewPriceView(price)
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.latest[price.Ticker] = price
+	for c := range h.clients {
+		if !c.accepts(price.Ticker) {
+			continue
+		}
+		select {
+		case c.send <- view:
+		default:
+			h.evict(c)
+		}
+	}
+}
+
+// Clients returns number of connected clients
+func (h *Hub) Clients() int {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return len(h.clients)
+}
+
+// Evictions returns number of clients evicted as too slow
+func (h *Hub) Evictions() int {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.evictions
+}
+
+// subscribe registers client and puts snapshot of the latest prices into its queue
+func (h *Hub) subscribe(tickers []pkg.Ticker) *client {
+	h.m.Lock()
+	defer h.m.Unlock()
+	c := &client{
+		send:    make(chan PriceView, h.bufferSize+len(h.latest)),
+		evicted: make(chan struct{}),
+	}
+	h.setFilter(c, tickers)
+	h.clients[c] = struct{}{}
+	return c
+}
+
+func (h *Hub) unsubscribe(c *client) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	delete(h.clients, c)
+}
+
+// updateFilter changes tickers client is interested in and sends snapshot of them
+func (h *Hub) updateFilter(c *client, tickers []pkg.Ticker) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if _, found := h.clients[c]; !found {
+		return
+	}
+	h.setFilter(c, tickers)
+}
+
+func (h *Hub) setFilter(c *client, tickers []pkg.Ticker) {
+	c.filter = nil
+	if len(tickers) > 0 {
+		c.filter = map[pkg.Ticker]struct{}{}
+		for _, ticker := range tickers {
+			c.filter[ticker] = struct{}{}
+		}
+	}
+	for ticker, price := range h.latest {
+		if !c.accepts(ticker) {
+			continue
+		}
+		select {
+		case c.send <- NewPriceView(price):
+		default:
+			h.evict(c)
+			return
+		}
+	}
+}
+
+func (h *Hub) evict(c *client) {
+	if _, found := h.clients[c]; !found {
+		return
+	}
+	delete(h.clients, c)
+	close(c.evicted)
+	h.evictions++
+}
+
+// ServeSSE streams fair prices as Server-Sent Events.
+// Optional "tickers" query parameter is a comma separated list of tickers to receive.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	c := h.subscribe(parseTickers(r.URL.Query().Get("tickers")))
+	defer h.unsubscribe(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.evicted:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case view := <-c.send:
+			data, err := json.Marshal(view)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: price\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// ServeWebSocket streams fair prices as WebSocket text messages.
+// Optional "tickers" query parameter is a comma separated list of tickers to receive,
+// the list could be changed later by sending {"tickers": ["BTC_USD"]} message.
+func (h *Hub) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return // response was written by upgrade
+	}
+	defer conn.Close()
+
+	c := h.subscribe(parseTickers(r.URL.Query().Get("tickers")))
+	defer h.unsubscribe(c)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			opcode, payload, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if opcode != wsOpText {
+				continue
+			}
+			var request struct {
+				Tickers []pkg.Ticker `json:"tickers"`
+			}
+			if err := json.Unmarshal(payload, &request); err == nil {
+				h.updateFilter(c, request.Tickers)
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-r.Context().Done():
+			_ = conn.WriteClose(wsCloseGoingAway, "server shutdown")
+			return
+		case <-c.evicted:
+			_ = conn.WriteClose(wsCloseTryAgainLater, "too slow")
+			return
+		case <-heartbeat.C:
+			if err := conn.WriteMessage(wsOpPing, nil); err != nil {
+				return
+			}
+		case view := <-c.send:
+			data, err := json.Marshal(view)
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(wsOpText, data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func parseTickers(value string) (result []pkg.Ticker) {
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, pkg.Ticker(part))
+		}
+	}
+	return result
+}
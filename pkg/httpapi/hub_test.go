@@ -0,0 +1,88 @@
+package httpapi
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Hub_SnapshotAndFilter(t *testing.T) {
+	h := NewHub(2)
+	h.Publish(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: pkg.BTCUSDTicker, Price: "1.000"}})
+	h.Publish(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: "ETH_USD", Price: "2.000"}})
+
+	c := h.subscribe([]pkg.Ticker{"ETH_USD"})
+	require.Len(t, c.send, 1)
+	assert.Equal(t, "2.000", *(<-c.send).Value)
+
+	h.Publish(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: pkg.BTCUSDTicker, Price: "3.000"}})
+	assert.Len(t, c.send, 0)
+	h.Publish(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: "ETH_USD", Price: "4.000"}})
+	assert.Equal(t, "4.000", *(<-c.send).Value)
+}
+
+func Test_Hub_SlowClientEvicted(t *testing.T) {
+	h := NewHub(2)
+	slow, fast := h.subscribe(nil), h.subscribe(nil)
+	for i := 0; i < 3; i++ {
+		h.Publish(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: pkg.BTCUSDTicker, Price: "1.000"}})
+		<-fast.send
+	}
+	select {
+	case <-slow.evicted:
+	default:
+		assert.Fail(t, "slow client wasn't evicted")
+	}
+	assert.Equal(t, 1, h.Clients())
+	assert.Equal(t, 1, h.Evictions())
+}
+
+func Test_Hub_WebSocket(t *testing.T) {
+	h := NewHub(2)
+	h.Publish(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: pkg.BTCUSDTicker, Price: "1.000"}})
+	srv := httptest.NewServer(http.HandlerFunc(h.ServeWebSocket))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+
+	rawKey := make([]byte, 16)
+	_, _ = rand.Read(rawKey)
+	key := base64.StdEncoding.EncodeToString(rawKey)
+	_, err = conn.Write([]byte("GET /?tickers=BTC_USD HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\n\r\n"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, wsAcceptKey(key), resp.Header.Get("Sec-WebSocket-Accept"))
+
+	header := make([]byte, 4)
+	_, err = io.ReadFull(reader, header)
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x80|wsOpText), header[0])
+	require.Equal(t, byte(126), header[1], "price message has 16 bit length")
+	payload := make([]byte, binary.BigEndian.Uint16(header[2:]))
+	_, err = io.ReadFull(reader, payload)
+	require.NoError(t, err)
+	var view PriceView
+	require.NoError(t, json.Unmarshal(payload, &view))
+	assert.Equal(t, "1.000", *view.Value)
+}
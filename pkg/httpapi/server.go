@@ -0,0 +1,255 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+)
+
+const (
+	DefaultHistorySize = 1000
+
+	shutdownTimeout = 5 * time.Second
+)
+
+type HealthStatus string
+
+const (
+	HealthOK       HealthStatus = "ok"
+	HealthDegraded HealthStatus = "degraded" // some tickers have no value or not enough sources
+	HealthStale    HealthStatus = "stale"    // some tickers weren't updated for too long or there is no data at all
+)
+
+// HealthView is JSON representation of service health
+type HealthView struct {
+	Status  HealthStatus                `json:"status"`
+	Tickers map[pkg.Ticker]HealthStatus `json:"tickers"`
+}
+
+type timeNow func() time.Time
+
+// Server keeps latest fair prices with bounded history and serves them over HTTP
+type Server struct {
+	m sync.RWMutex
+
+	latest      map[pkg.Ticker]pkg.FairPriceResult
+	history     map[pkg.Ticker][]pkg.FairPriceResult
+	historySize int
+	staleAfter  time.Duration
+	timeNow     timeNow
+	mux         *http.ServeMux
+}
+
+// NewServer constructor. Ticker is reported as stale if it wasn't updated within staleAfter.
+func NewServer(historySize int, staleAfter time.Duration, tn timeNow) *Server {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	s := &Server{
+		latest:      map[pkg.Ticker]pkg.FairPriceResult{},
+		history:     map[pkg.Ticker][]pkg.FairPriceResult{},
+		historySize: historySize,
+		staleAfter:  staleAfter,
+		timeNow:     tn,
+		mux:         http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/prices", s.handlePrices)
+	s.mux.HandleFunc("/prices/", s.handleTicker)
+	s.mux.HandleFunc("/health", s.handleHealth)
+	return s
+}
+
+// Update stores single fair price
+func (s *Server) Update(price pkg.FairPriceResult) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.latest[price.Ticker] = price
+	history := append(s.history[price.Ticker], price)
+	if len(history) > s.historySize {
+		history = history[len(history)-s.historySize:]
+	}
+	s.history[price.Ticker] = history
+}
+
+// Handle registers additional handler on the server's mux
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves API on addr until ctx is done
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves API on the listener until ctx is done.
+// Requests inherit ctx, so streaming handlers are stopped on shutdown instead of being waited for.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:     s,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// Latest returns latest fair prices of all tickers sorted by ticker
+func (s *Server) Latest() []pkg.FairPriceResult {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	result := make([]pkg.FairPriceResult, 0, len(s.latest))
+	for _, price := range s.latest {
+		result = append(result, price)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Ticker < result[j].Ticker })
+	return result
+}
+
+// GET /prices
+func (s *Server) handlePrices(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	latest := s.Latest()
+	views := make([]PriceView, 0, len(latest))
+	for _, price := range latest {
+		views = append(views, NewPriceView(price))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// GET /prices/{ticker} and GET /prices/{ticker}/history?limit=N
+func (s *Server) handleTicker(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/prices/"), "/"), "/")
+	ticker := pkg.Ticker(parts[0])
+	switch {
+	case len(parts) == 1 && ticker != "":
+		s.m.RLock()
+		price, found := s.latest[ticker]
+		s.m.RUnlock()
+		if !found {
+			writeError(w, http.StatusNotFound, "unknown ticker")
+			return
+		}
+		writeJSON(w, http.StatusOK, NewPriceView(price))
+	case len(parts) == 2 && parts[1] == "history":
+		s.handleHistory(w, r, ticker)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request, ticker pkg.Ticker) {
+	limit := s.historySize
+	if str := r.URL.Query().Get("limit"); str != "" {
+		value, err := strconv.Atoi(str)
+		if err != nil || value <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		if value < limit {
+			limit = value
+		}
+	}
+	s.m.RLock()
+	history, found := s.history[ticker]
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	views := make([]PriceView, 0, len(history))
+	for _, price := range history {
+		views = append(views, NewPriceView(price))
+	}
+	s.m.RUnlock()
+	if !found {
+		writeError(w, http.StatusNotFound, "unknown ticker")
+		return
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// GET /health
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	health := s.Health()
+	code := http.StatusOK
+	if health.Status == HealthStale {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, health)
+}
+
+// Health reports stale tickers and tickers without reliable value
+func (s *Server) Health() HealthView {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	result := HealthView{Status: HealthOK, Tickers: map[pkg.Ticker]HealthStatus{}}
+	if len(s.latest) == 0 {
+		result.Status = HealthStale
+		return result
+	}
+	now := s.timeNow()
+	for ticker, price := range s.latest {
+		status := HealthOK
+		switch {
+		case s.staleAfter > 0 && now.Sub(price.Time) > s.staleAfter:
+			status = HealthStale
+		case price.Status != pkg.StatusOK:
+			status = HealthDegraded
+		}
+		result.Tickers[ticker] = status
+		if status == HealthStale || (status == HealthDegraded && result.Status == HealthOK) {
+			result.Status = status
+		}
+	}
+	return result
+}
+
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+	w.Header().Set("Allow", http.MethodGet)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(value) // client has gone, nothing to do
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
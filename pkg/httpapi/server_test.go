@@ -0,0 +1,107 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tn = time.Date(2020, 1, 1, 10, 5, 0, 0, time.UTC)
+
+func fixedTimeNow() time.Time {
+	return tn
+}
+
+func get(t *testing.T, s *Server, url string, result interface{}) int {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(result))
+	return rec.Code
+}
+
+func Test_Prices_ExpectLatestAndBoundedHistory(t *testing.T) {
+	s := NewServer(2, time.Minute, fixedTimeNow)
+	for _, price := range []string{"1.000", "2.000", "3.000"} {
+		s.Update(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: pkg.BTCUSDTicker, Price: price, Time: tn}, Status: pkg.StatusOK, SourceCount: 2})
+	}
+	s.Update(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: "ETH_USD", Price: collector.NoValue, Time: tn}, Status: pkg.StatusNoValue})
+
+	var all []PriceView
+	require.Equal(t, http.StatusOK, get(t, s, "/prices", &all))
+	require.Len(t, all, 2)
+	assert.Equal(t, pkg.BTCUSDTicker, all[0].Ticker)
+	assert.Equal(t, "3.000", *all[0].Value)
+	assert.Equal(t, 2, all[0].Sources)
+	assert.Nil(t, all[1].Value)
+
+	var single PriceView
+	require.Equal(t, http.StatusOK, get(t, s, "/prices/BTC_USD", &single))
+	assert.Equal(t, "3.000", *single.Value)
+
+	var history []PriceView
+	require.Equal(t, http.StatusOK, get(t, s, "/prices/BTC_USD/history", &history))
+	require.Len(t, history, 2)
+	assert.Equal(t, "2.000", *history[0].Value)
+	require.Equal(t, http.StatusOK, get(t, s, "/prices/BTC_USD/history?limit=1", &history))
+	require.Len(t, history, 1)
+	assert.Equal(t, "3.000", *history[0].Value)
+
+	var errResp map[string]string
+	assert.Equal(t, http.StatusNotFound, get(t, s, "/prices/XRP_USD", &errResp))
+	assert.Equal(t, http.StatusBadRequest, get(t, s, "/prices/BTC_USD/history?limit=x", &errResp))
+}
+
+func Test_Health(t *testing.T) {
+	s := NewServer(10, time.Minute, fixedTimeNow)
+	var health HealthView
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, s, "/health", &health))
+	assert.Equal(t, HealthStale, health.Status)
+
+	s.Update(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: pkg.BTCUSDTicker, Price: "1.000", Time: tn}, Status: pkg.StatusOK})
+	assert.Equal(t, http.StatusOK, get(t, s, "/health", &health))
+	assert.Equal(t, HealthOK, health.Status)
+
+	s.Update(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: "ETH_USD", Price: "1.000", Time: tn}, Status: pkg.StatusDegraded})
+	assert.Equal(t, http.StatusOK, get(t, s, "/health", &health))
+	assert.Equal(t, HealthDegraded, health.Status)
+
+	s.Update(pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: "ETH_USD", Price: "1.000", Time: tn.Add(-time.Hour)}, Status: pkg.StatusOK})
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, s, "/health", &health))
+	assert.Equal(t, HealthStale, health.Status)
+	assert.Equal(t, HealthStale, health.Tickers["ETH_USD"])
+}
+
+func Test_Serve_ShutdownWithStreamingClient(t *testing.T) {
+	s, h := NewServer(0, 0, fixedTimeNow), NewHub(0)
+	s.Handle("/stream/sse", http.HandlerFunc(h.ServeSSE))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, ln)
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/stream/sse")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 1, h.Clients())
+
+	cancel()
+	select {
+	case err := <-served:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "shutdown waits for streaming client")
+	}
+}
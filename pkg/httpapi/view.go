@@ -0,0 +1,44 @@
+package httpapi
+
+import (
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/collector"
+)
+
+// PriceView is JSON representation of a fair price
+type PriceView struct {
+	Ticker      pkg.Ticker        `json:"ticker"`
+	Value       *string           `json:"value"` // null if there were no prices within the period
+	Status      pkg.PriceStatus   `json:"status"`
+	Time        time.Time         `json:"time"`
+	PeriodStart time.Time         `json:"period_start"`
+	PeriodEnd   time.Time         `json:"period_end"`
+	Sources     int               `json:"sources"`
+	Partial     bool              `json:"partial,omitempty"` // period was cut short by shutdown
+	Group       string            `json:"group,omitempty"`   // active source group of failover
+	Candle      *collector.Candle `json:"candle,omitempty"`
+	Impact      *collector.Impact `json:"impact,omitempty"`
+}
+
+// NewPriceView converts fair price into its JSON representation
+func NewPriceView(price pkg.FairPriceResult) PriceView {
+	view := PriceView{
+		Ticker:      price.Ticker,
+		Status:      price.Status,
+		Time:        price.Time,
+		PeriodStart: price.PeriodStart,
+		PeriodEnd:   price.PeriodEnd,
+		Sources:     price.SourceCount,
+		Partial:     price.Partial,
+		Group:       price.Group,
+		Candle:      price.Candle,
+		Impact:      price.Impact,
+	}
+	if price.Price != collector.NoValue {
+		value := price.Price
+		view.Value = &value
+	}
+	return view
+}
@@ -0,0 +1,228 @@
+package httpapi
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal server side of RFC 6455, enough to push messages and to receive small client requests
+
+const (
+	wsGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsMaxPayload   = 64 << 10
+	wsWriteTimeout = 10 * time.Second
+
+	wsOpContinuation byte = 0x0
+	wsOpText         byte = 0x1
+	wsOpBinary       byte = 0x2
+	wsOpClose        byte = 0x8
+	wsOpPing         byte = 0x9
+	wsOpPong         byte = 0xA
+
+	wsCloseGoingAway     = 1001
+	wsCloseTryAgainLater = 1013
+)
+
+var (
+	errWsNotMasked       = errors.New("websocket: client frame is not masked")
+	errWsPayloadTooLarge = errors.New("websocket: payload is too large")
+	errWsBadFrame        = errors.New("websocket: unexpected frame")
+)
+
+type wsConn struct {
+	wm sync.Mutex
+
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !allowGet(w, r) {
+		return nil, errWsBadFrame
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		writeError(w, http.StatusBadRequest, "websocket upgrade expected")
+		return nil, errWsBadFrame
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusUpgradeRequired, "unsupported websocket version")
+		return nil, errWsBadFrame
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "websocket key is missing")
+		return nil, errWsBadFrame
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "websocket is not supported")
+		return nil, errWsBadFrame
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	c := &wsConn{conn: conn, rw: rw}
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+// ReadMessage returns next data message. Control frames are processed internally,
+// io.EOF is returned when client closed the connection.
+func (c *wsConn) ReadMessage() (byte, []byte, error) {
+	var (
+		opcode  byte
+		message []byte
+	)
+	for {
+		fin, frameOpcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch frameOpcode {
+		case wsOpPing:
+			if err := c.WriteMessage(wsOpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			_ = c.WriteMessage(wsOpClose, payload)
+			return 0, nil, io.EOF
+		case wsOpText, wsOpBinary:
+			if message != nil {
+				return 0, nil, errWsBadFrame
+			}
+			opcode = frameOpcode
+		case wsOpContinuation:
+			if message == nil {
+				return 0, nil, errWsBadFrame
+			}
+		default:
+			return 0, nil, errWsBadFrame
+		}
+		message = append(message, payload...)
+		if len(message) > wsMaxPayload {
+			return 0, nil, errWsPayloadTooLarge
+		}
+		if message == nil {
+			message = []byte{}
+		}
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.rw, header); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode := header[0]&0x80 != 0, header[0]&0x0F
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, errWsNotMasked
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(c.rw, ext); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(c.rw, ext); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if length > wsMaxPayload {
+		return false, 0, nil, errWsPayloadTooLarge
+	}
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(c.rw, mask); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage writes single unfragmented frame, safe for concurrent use
+func (c *wsConn) WriteMessage(opcode byte, payload []byte) error {
+	c.wm.Lock()
+	defer c.wm.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// WriteClose sends close frame with status code and reason
+func (c *wsConn) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return c.WriteMessage(wsOpClose, append(payload, reason...))
+}
@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/metrics"
+)
+
+var periodSampleBuckets = []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// Metrics of the pipeline. Nil *Metrics disables instrumentation.
+type Metrics struct {
+	PricesReceived    *metrics.CounterVec   // ticker, source
+	PricesUnmatched   *metrics.CounterVec   // ticker, source
+	PricesUnconverted *metrics.CounterVec   // ticker, source
+	Duplicates        *metrics.CounterVec   // ticker, source
+	Coalesced         *metrics.CounterVec   // ticker, source
+	SequenceGaps      *metrics.CounterVec   // ticker, source
+	OutOfOrder        *metrics.CounterVec   // ticker, source
+	SourceDisconnects *metrics.CounterVec   // ticker, source
+	BreakerChanges    *metrics.CounterVec   // ticker, source, state
+	BreakerDropped    *metrics.CounterVec   // ticker, source
+	ParseErrors       *metrics.CounterVec   // ticker
+	PricesTooOld      *metrics.CounterVec   // ticker
+	OutputsDropped    *metrics.CounterVec   // ticker
+	PeriodSamples     *metrics.HistogramVec // ticker
+	Latency           *metrics.HistogramVec // ticker
+}
+
+// NewMetrics registers pipeline metrics in the registry
+func NewMetrics(r *metrics.Registry) *Metrics {
+	return &Metrics{
+		PricesReceived: r.NewCounterVec("fairprice_prices_received_total",
+			"Prices received from sources.", "ticker", "source"),
+		PricesUnmatched: r.NewCounterVec("fairprice_prices_unmatched_total",
+			"Prices dropped because their ticker doesn't match the subscribed one.", "ticker", "source"),
+		PricesUnconverted: r.NewCounterVec("fairprice_prices_unconverted_total",
+			"Prices dropped because conversion rate is missing or too old.", "ticker", "source"),
+		Duplicates: r.NewCounterVec("fairprice_duplicates_dropped_total",
+			"Repeated prints dropped by multiplexor.", "ticker", "source"),
+		Coalesced: r.NewCounterVec("fairprice_prices_coalesced_total",
+			"Prices replaced by newer ones of the same source while waiting for output.", "ticker", "source"),
+		SequenceGaps: r.NewCounterVec("fairprice_sequence_gaps_total",
+			"Gaps in sequence numbers of source prices.", "ticker", "source"),
+		OutOfOrder: r.NewCounterVec("fairprice_out_of_order_total",
+			"Source prices with sequence number not greater than the previous one.", "ticker", "source"),
+		SourceDisconnects: r.NewCounterVec("fairprice_source_disconnects_total",
+			"Source streams finished because of an error or closed channel.", "ticker", "source"),
+		BreakerChanges: r.NewCounterVec("fairprice_breaker_changes_total",
+			"Changes of circuit breaker states of sources.", "ticker", "source", "state"),
+		BreakerDropped: r.NewCounterVec("fairprice_breaker_dropped_total",
+			"Prices dropped by circuit breaker: invalid, deviated or sent by quarantined source.", "ticker", "source"),
+		ParseErrors: r.NewCounterVec("fairprice_parse_errors_total",
+			"Prices rejected by collector.", "ticker"),
+		PricesTooOld: r.NewCounterVec("fairprice_prices_too_old_total",
+			"Prices dropped because they are older than the current period.", "ticker"),
+		OutputsDropped: r.NewCounterVec("fairprice_outputs_dropped_total",
+			"Fair prices dropped because output channel was busy.", "ticker"),
+		PeriodSamples: r.NewHistogramVec("fairprice_period_samples",
+			"Number of prices collected within a period.", periodSampleBuckets, "ticker"),
+		Latency: r.NewHistogramVec("fairprice_latency_seconds",
+			"Time from price event to publishing of the fair price it contributed to.", metrics.DefaultBuckets, "ticker"),
+	}
+}
+
+func (m *Metrics) priceReceived(ticker Ticker, source string) {
+	if m != nil {
+		m.PricesReceived.Inc(string(ticker), source)
+	}
+}
+
+func (m *Metrics) priceUnmatched(ticker Ticker, source string) {
+	if m != nil {
+		m.PricesUnmatched.Inc(string(ticker), source)
+	}
+}
+
+func (m *Metrics) priceUnconverted(ticker Ticker, source string) {
+	if m != nil {
+		m.PricesUnconverted.Inc(string(ticker), source)
+	}
+}
+
+func (m *Metrics) duplicateDropped(ticker Ticker, source string) {
+	if m != nil {
+		m.Duplicates.Inc(string(ticker), source)
+	}
+}
+
+func (m *Metrics) priceCoalesced(ticker Ticker, source string) {
+	if m != nil {
+		m.Coalesced.Inc(string(ticker), source)
+	}
+}
+
+func (m *Metrics) sequenceGap(ticker Ticker, source string) {
+	if m != nil {
+		m.SequenceGaps.Inc(string(ticker), source)
+	}
+}
+
+func (m *Metrics) outOfOrder(ticker Ticker, source string) {
+	if m != nil {
+		m.OutOfOrder.Inc(string(ticker), source)
+	}
+}
+
+func (m *Metrics) sourceDisconnected(ticker Ticker, source string) {
+	if m != nil {
+		m.SourceDisconnects.Inc(string(ticker), source)
+	}
+}
+
+func (m *Metrics) breakerChanged(ticker Ticker, source string, state BreakerState) {
+	if m != nil {
+		m.BreakerChanges.Inc(string(ticker), source, string(state))
+	}
+}
+
+func (m *Metrics) breakerDropped(ticker Ticker, source string) {
+	if m != nil {
+		m.BreakerDropped.Inc(string(ticker), source)
+	}
+}
+
+func (m *Metrics) parseError(ticker Ticker) {
+	if m != nil {
+		m.ParseErrors.Inc(string(ticker))
+	}
+}
+
+func (m *Metrics) priceTooOld(ticker Ticker) {
+	if m != nil {
+		m.PricesTooOld.Inc(string(ticker))
+	}
+}
+
+func (m *Metrics) outputDropped(ticker Ticker) {
+	if m != nil {
+		m.OutputsDropped.Inc(string(ticker))
+	}
+}
+
+func (m *Metrics) published(ticker Ticker, eventTimes []time.Time, publishTime time.Time) {
+	if m == nil {
+		return
+	}
+	m.PeriodSamples.Observe(float64(len(eventTimes)), string(ticker))
+	for _, t := range eventTimes {
+		m.Latency.Observe(publishTime.Sub(t).Seconds(), string(ticker))
+	}
+}
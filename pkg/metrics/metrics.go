@@ -0,0 +1,273 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds
+var DefaultBuckets = []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+// Registry keeps metrics and writes them in Prometheus text exposition format
+type Registry struct {
+	m sync.Mutex
+
+	families []*family
+	names    map[string]struct{}
+}
+
+// NewRegistry constructor
+func NewRegistry() *Registry {
+	return &Registry{
+		names: map[string]struct{}{},
+	}
+}
+
+// NewCounterVec registers counter with given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{family: r.register(name, help, counterType, nil, labels)}
+}
+
+// NewGaugeVec registers gauge with given label names
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{family: r.register(name, help, gaugeType, nil, labels)}
+}
+
+// NewHistogramVec registers histogram with given upper bounds of buckets and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &HistogramVec{family: r.register(name, help, histogramType, sorted, labels)}
+}
+
+func (r *Registry) register(name, help string, typ metricType, buckets []float64, labels []string) *family {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if _, found := r.names[name]; found {
+		panic(fmt.Sprintf("metric %q is already registered", name))
+	}
+	f := &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		buckets: buckets,
+		labels:  labels,
+		series:  map[string]*series{},
+	}
+	r.names[name] = struct{}{}
+	r.families = append(r.families, f)
+	return f
+}
+
+// WriteTo writes all metrics in Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.m.Lock()
+	families := append([]*family(nil), r.families...)
+	r.m.Unlock()
+
+	buf := &bytes.Buffer{}
+	for _, f := range families {
+		f.write(buf)
+	}
+	return buf.WriteTo(w)
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // counter and gauge value, sum of histogram
+	counts      []uint64 // histogram counts per bucket, not cumulative
+	count       uint64   // histogram total count
+}
+
+type family struct {
+	m sync.Mutex
+
+	name    string
+	help    string
+	typ     metricType
+	buckets []float64
+	labels  []string
+	series  map[string]*series
+}
+
+// with returns series for label values, caller must hold the lock
+func (f *family) with(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %q expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, found := f.series[key]
+	if !found {
+		s = &series{labelValues: append([]string(nil), values...)}
+		if f.typ == histogramType {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) write(buf *bytes.Buffer) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	fmt.Fprintf(buf, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", f.name, f.typ)
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.typ != histogramType {
+			fmt.Fprintf(buf, "%s%s %s\n", f.name, f.formatLabels(s.labelValues, "", ""), formatFloat(s.value))
+			continue
+		}
+		cumulative := uint64(0)
+		for i, upper := range f.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", f.name, f.formatLabels(s.labelValues, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", f.name, f.formatLabels(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", f.name, f.formatLabels(s.labelValues, "", ""), formatFloat(s.value))
+		fmt.Fprintf(buf, "%s_count%s %d\n", f.name, f.formatLabels(s.labelValues, "", ""), s.count)
+	}
+}
+
+func (f *family) formatLabels(values []string, extraName, extraValue string) string {
+	parts := make([]string, 0, len(values)+1)
+	for i, value := range values {
+		parts = append(parts, f.labels[i]+`="`+escapeLabel(value)+`"`)
+	}
+	if extraName != "" {
+		parts = append(parts, extraName+`="`+extraValue+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// CounterVec is a counter partitioned by labels. Nil vector is a no-op.
+type CounterVec struct {
+	family *family
+}
+
+// Inc increments counter of given label values
+func (v *CounterVec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Add adds non-negative delta to counter of given label values
+func (v *CounterVec) Add(delta float64, labelValues ...string) {
+	if v == nil || delta < 0 {
+		return
+	}
+	v.family.m.Lock()
+	v.family.with(labelValues).value += delta
+	v.family.m.Unlock()
+}
+
+// Value returns current counter value
+func (v *CounterVec) Value(labelValues ...string) float64 {
+	if v == nil {
+		return 0
+	}
+	v.family.m.Lock()
+	defer v.family.m.Unlock()
+	return v.family.with(labelValues).value
+}
+
+// GaugeVec is a gauge partitioned by labels. Nil vector is a no-op.
+type GaugeVec struct {
+	family *family
+}
+
+// Set sets gauge of given label values
+func (v *GaugeVec) Set(value float64, labelValues ...string) {
+	if v == nil {
+		return
+	}
+	v.family.m.Lock()
+	v.family.with(labelValues).value = value
+	v.family.m.Unlock()
+}
+
+// Add adds delta to gauge of given label values
+func (v *GaugeVec) Add(delta float64, labelValues ...string) {
+	if v == nil {
+		return
+	}
+	v.family.m.Lock()
+	v.family.with(labelValues).value += delta
+	v.family.m.Unlock()
+}
+
+// HistogramVec is a histogram partitioned by labels. Nil vector is a no-op.
+type HistogramVec struct {
+	family *family
+}
+
+// Observe adds single observation to histogram of given label values
+func (v *HistogramVec) Observe(value float64, labelValues ...string) {
+	if v == nil {
+		return
+	}
+	v.family.m.Lock()
+	defer v.family.m.Unlock()
+	s := v.family.with(labelValues)
+	s.count++
+	s.value += value
+	for i, upper := range v.family.buckets {
+		if value <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriteTo_TextFormat(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounterVec("requests_total", "Requests.", "source")
+	gauge := r.NewGaugeVec("clients", "Connected clients.")
+	histogram := r.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1}, "ticker")
+
+	counter.Inc("b")
+	counter.Add(2, `a"1`)
+	counter.Add(-1, "b") // counters never go down
+	gauge.Set(3)
+	histogram.Observe(0.05, "BTC_USD")
+	histogram.Observe(0.5, "BTC_USD")
+	histogram.Observe(5, "BTC_USD")
+
+	buf := &bytes.Buffer{}
+	_, err := r.WriteTo(buf)
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{source="a\"1"} 2
+requests_total{source="b"} 1
+# HELP clients Connected clients.
+# TYPE clients gauge
+clients 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{ticker="BTC_USD",le="0.1"} 1
+latency_seconds_bucket{ticker="BTC_USD",le="1"} 2
+latency_seconds_bucket{ticker="BTC_USD",le="+Inf"} 3
+latency_seconds_sum{ticker="BTC_USD"} 5.55
+latency_seconds_count{ticker="BTC_USD"} 3
+`, buf.String())
+}
+
+func Test_NilVectors_NoPanic(t *testing.T) {
+	var (
+		counter   *CounterVec
+		gauge     *GaugeVec
+		histogram *HistogramVec
+	)
+	counter.Inc("a")
+	gauge.Set(1)
+	histogram.Observe(1)
+	assert.Zero(t, counter.Value("a"))
+}
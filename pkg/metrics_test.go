@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/dshipenok/tickers/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Metrics_CountDroppedAndRejectedPrices(t *testing.T) {
+	waitForNextPeriod := make(chan struct{}, 10)
+	pipelineMetrics := NewMetrics(metrics.NewRegistry())
+	m := NewMultiplexor().WithMetrics(pipelineMetrics)
+	resultCh := m.Subscribe(valuesToStreams([][]interface{}{{
+		&TickerPrice{Time: fixedTimeNow().Add(-time.Hour), Price: "1.0"},
+		&TickerPrice{Time: fixedTimeNow().Add(time.Hour), Price: "abc"},
+		&TickerPrice{Time: fixedTimeNow().Add(time.Hour), Price: "2.0"},
+		waitForNextPeriod,
+		"Disconnected",
+	}}))
+	p := NewFairPrice(collector.NewAverage(3), fixedTimeNow).WithMetrics(pipelineMetrics)
+	output, wait := make(chan FairPriceResult, 1), make(chan struct{})
+	go func() {
+		for range output {
+			waitForNextPeriod <- struct{}{}
+		}
+		close(wait)
+	}()
+	p.Start(context.Background(), resultCh, periodDuration, output)
+	close(output)
+	<-wait
+
+	assert.EqualValues(t, 3, pipelineMetrics.PricesReceived.Value(string(BTCUSDTicker), "source-0"))
+	assert.EqualValues(t, 1, pipelineMetrics.PricesTooOld.Value(string(BTCUSDTicker)))
+	assert.EqualValues(t, 1, pipelineMetrics.ParseErrors.Value(string(BTCUSDTicker)))
+	assert.EqualValues(t, 1, pipelineMetrics.SourceDisconnects.Value(string(BTCUSDTicker), "source-0"))
+}
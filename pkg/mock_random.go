@@ -1,41 +1,162 @@
 package pkg
 
 import (
+	"context"
+	"math"
 	"math/rand"
 	"strconv"
+	"sync"
 	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
 )
 
 const priceRange = 1000
 
+// MockRandomOptions configures random prices generation
+type MockRandomOptions struct {
+	Interval time.Duration      // period of price generation
+	Base     float64            // middle of generated prices
+	Range    float64            // width of generated prices range
+	Bases    map[Ticker]float64 // per-ticker override of Base
+	Depth    int                // number of order book levels sent after every price, books aren't sent if 0
+	Quote    string             // quote asset of sent prices, e.g. USDT, subscribed one is used if empty
+	Symbols  *SymbolConvention  // convention of sent tickers, canonical if nil
+	Sequence bool               // prices are numbered by per-subscription sequence
+}
+
+// DefaultMockRandomOptions are options used by NewMockRandomStream
+func DefaultMockRandomOptions() MockRandomOptions {
+	return MockRandomOptions{
+		Interval: time.Second,
+		Base:     40000.,
+		Range:    priceRange,
+	}
+}
+
 type MockRandomStream struct {
 	priceCh chan TickerPrice
 	errCh   chan error
+	opts    MockRandomOptions
+
+	m          sync.Mutex
+	generators map[Ticker]struct{} // tickers with running generator of SubscribePriceStream
 }
 
 // NewMockRandomStream constructor
 func NewMockRandomStream() *MockRandomStream {
+	return NewMockRandomStreamWithOptions(DefaultMockRandomOptions())
+}
+
+// NewMockRandomStreamWithOptions constructor
+func NewMockRandomStreamWithOptions(opts MockRandomOptions) *MockRandomStream {
 	return &MockRandomStream{
-		priceCh: make(chan TickerPrice, 1),
-		errCh:   make(chan error),
+		priceCh:    make(chan TickerPrice, 1),
+		errCh:      make(chan error),
+		opts:       opts,
+		generators: map[Ticker]struct{}{},
 	}
 }
 
-func (m *MockRandomStream) SubscribePriceStream(Ticker) (chan TickerPrice, chan error) {
-	go m.generate()
+// SubscribePriceStream starts generator of the ticker once, repeated subscriptions reuse it
+func (m *MockRandomStream) SubscribePriceStream(ticker Ticker) (chan TickerPrice, chan error) {
+	m.m.Lock()
+	if _, found := m.generators[ticker]; !found {
+		m.generators[ticker] = struct{}{}
+		go m.generate(ticker)
+	}
+	m.m.Unlock()
 	return m.priceCh, m.errCh
 }
 
-func (m *MockRandomStream) generate() {
-	ticker := time.NewTicker(time.Second)
+// SubscribePriceStreamContext starts independent generator which stops with the subscription
+func (m *MockRandomStream) SubscribePriceStreamContext(ctx context.Context, ticker Ticker) (ISubscription, error) {
+	sub := NewSubscription(ctx)
+	go func() {
+		tick := time.NewTicker(m.opts.Interval)
+		defer tick.Stop()
+		var seq uint64
+		for {
+			select {
+			case <-sub.Done():
+				return
+			case <-tick.C:
+				price := m.randomPrice(ticker)
+				if m.opts.Sequence {
+					seq++
+					price.Seq = seq
+				}
+				if !sub.SendPrice(price) {
+					return
+				}
+				if m.opts.Depth > 0 && !sub.SendPrice(m.randomBook(price)) {
+					return
+				}
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (m *MockRandomStream) generate(ticker Ticker) {
+	tick := time.NewTicker(m.opts.Interval)
+	var seq uint64
 	for {
 		select {
-		case <-ticker.C:
-			value := 40000. + rand.Float64()*priceRange - (priceRange * 0.5)
-			m.priceCh <- TickerPrice{
-				Price: strconv.FormatFloat(value, 'f', 3, 64),
-				Time:  time.Now(),
+		case <-tick.C:
+			price := m.randomPrice(ticker)
+			if m.opts.Sequence {
+				seq++
+				price.Seq = seq
+			}
+			m.priceCh <- price
+			if m.opts.Depth > 0 {
+				m.priceCh <- m.randomBook(price)
 			}
 		}
 	}
 }
+
+func (m *MockRandomStream) randomPrice(ticker Ticker) TickerPrice {
+	base, found := m.opts.Bases[ticker]
+	if !found {
+		base = m.opts.Base
+	}
+	value := base + rand.Float64()*m.opts.Range - (m.opts.Range * 0.5)
+	return TickerPrice{
+		Ticker: m.symbol(ticker),
+		Price:  strconv.FormatFloat(value, 'f', 3, 64),
+		Time:   time.Now(),
+	}
+}
+
+// randomBook generates order book around the price
+func (m *MockRandomStream) randomBook(price TickerPrice) TickerPrice {
+	mid, _ := strconv.ParseFloat(price.Price, 64)
+	step := math.Max(m.opts.Range*0.001, 0.001)
+	book := &collector.Book{Time: price.Time}
+	for i := 0; i < m.opts.Depth; i++ {
+		offset := step * (float64(i) + 0.5)
+		book.Bids = append(book.Bids, collector.Level{Price: mid - offset, Size: rand.Float64() * 10})
+		book.Asks = append(book.Asks, collector.Level{Price: mid + offset, Size: rand.Float64() * 10})
+	}
+	return TickerPrice{Ticker: price.Ticker, Time: price.Time, Book: book}
+}
+
+// symbol formats ticker the way the source is configured to send it
+func (m *MockRandomStream) symbol(ticker Ticker) Ticker {
+	if m.opts.Quote == "" && m.opts.Symbols == nil {
+		return ticker
+	}
+	pair, err := ticker.Pair()
+	if err != nil {
+		return ticker
+	}
+	if m.opts.Quote != "" {
+		pair.Quote = CanonicalAsset(m.opts.Quote)
+	}
+	if m.opts.Symbols == nil {
+		return pair.Ticker()
+	}
+	return Ticker(m.opts.Symbols.Format(pair))
+}
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"context"
 	"errors"
 	"time"
 )
@@ -44,3 +45,47 @@ func newMockStream(vals ...interface{}) *mockStream {
 func (m *mockStream) SubscribePriceStream(Ticker) (chan TickerPrice, chan error) {
 	return m.priceCh, m.errCh
 }
+
+type mockStreamV2 struct {
+	closed chan struct{}
+}
+
+// newMockStreamV2 constructor
+func newMockStreamV2() *mockStreamV2 {
+	return &mockStreamV2{
+		closed: make(chan struct{}),
+	}
+}
+
+func (m *mockStreamV2) SubscribePriceStreamContext(ctx context.Context, _ Ticker) (ISubscription, error) {
+	sub := NewSubscription(ctx)
+	go func() {
+		<-sub.Done()
+		close(m.closed)
+	}()
+	return sub, nil
+}
+
+// mockSequenceStream sends prepared prices on every subscription and reports error after them
+type mockSequenceStream struct {
+	subscriptions [][]TickerPrice
+	subscribed    int
+}
+
+func (m *mockSequenceStream) SubscribePriceStreamContext(ctx context.Context, _ Ticker) (ISubscription, error) {
+	if m.subscribed >= len(m.subscriptions) {
+		return nil, errors.New("no more subscriptions")
+	}
+	prices := m.subscriptions[m.subscribed]
+	m.subscribed++
+	sub := NewSubscription(ctx)
+	go func() {
+		for _, price := range prices {
+			if !sub.SendPrice(price) {
+				return
+			}
+		}
+		sub.SendError(errors.New("Disconnected"))
+	}()
+	return sub, nil
+}
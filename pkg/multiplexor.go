@@ -1,53 +1,612 @@
 package pkg
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
 
-type Multiplexor struct{}
+const eventsBufferSize = 100
+
+var (
+	ErrNotStarted      = errors.New("multiplexor is not started")
+	ErrMultiplexorDone = errors.New("multiplexor is done")
+	ErrAlreadyStarted  = errors.New("multiplexor is already started")
+	ErrSourceExists    = errors.New("source already exists")
+	ErrSourceNotFound  = errors.New("source not found")
+	ErrSourceNameEmpty = errors.New("source name is empty")
+	ErrSubscriberIsNil = errors.New("subscriber is nil")
+)
+
+type SourceEventType string
+
+const (
+	SourceAdded        SourceEventType = "added"
+	SourceRemoved      SourceEventType = "removed"
+	SourceDisconnected SourceEventType = "disconnected"
+	SourceSequenceGap  SourceEventType = "sequence_gap"
+	SourceOutOfOrder   SourceEventType = "out_of_order"
+	SourceResubscribed SourceEventType = "resubscribed"
+	SourceBreaker      SourceEventType = "breaker" // state of source's circuit breaker was changed
+)
+
+// errResubscribe stops stream which has to be subscribed again
+var errResubscribe = errors.New("resubscribe")
+
+// SourceEvent reports changes of multiplexor's sources
+type SourceEvent struct {
+	Type   SourceEventType
+	Source string
+	Time   time.Time
+	Err    error // reason of disconnection or opening of circuit breaker if any
+
+	// sequence numbers of gap and out-of-order events
+	Expected uint64
+	Got      uint64
+	// new state of circuit breaker of breaker events
+	State BreakerState
+}
+
+// NamedSource is a subscriber with a name used to identify it within multiplexor
+type NamedSource struct {
+	Name       string
+	Subscriber IPriceStreamSubscriberV2
+}
+
+type source struct {
+	cancel  context.CancelFunc
+	breaker *circuitBreaker // nil if disabled, guarded by multiplexor's mutex
+}
+
+// Multiplexor combines streams of several sources into single channel.
+// Each multiplexor serves single subscription, sources could be added or removed while it runs.
+type Multiplexor struct {
+	m sync.Mutex
+
+	ticker      Ticker
+	equivalence QuoteEquivalence
+	converter   *QuoteConverter
+	dedup       *deduplicator
+	resubscribe bool            // resubscribe on sequence gap
+	breaker     *BreakerOptions // per-source circuit breaker, disabled if nil
+	references  map[string]breakerReference
+	rateLimit   *RateLimitOptions // fair scheduling of output, disabled if nil
+	scheduler   *fairScheduler
+	duplicates  map[string]int // dropped duplicates by source
+	metrics     *Metrics
+	ctx         context.Context
+	output      chan TickerPrice
+	events      chan SourceEvent
+	sources     map[string]*source
+	running     int // number of alive stream goroutines, removed ones included
+	done        bool
+	doneCh      chan struct{}
+}
 
 // NewMultiplexor constructor
 func NewMultiplexor() *Multiplexor {
-	return &Multiplexor{}
+	return &Multiplexor{
+		ticker:  BTCUSDTicker,
+		events:  make(chan SourceEvent, eventsBufferSize),
+		sources: map[string]*source{},
+		doneCh:  make(chan struct{}),
+	}
+}
+
+// WithTicker sets ticker sources are subscribed to. Must be called before subscription.
+func (m *Multiplexor) WithTicker(ticker Ticker) *Multiplexor {
+	m.ticker = ticker
+	return m
+}
+
+// WithQuoteEquivalence makes multiplexor accept prices of pairs with equivalent quote, e.g. BTC_USDT for BTC_USD.
+// Must be called before subscription.
+func (m *Multiplexor) WithQuoteEquivalence(equivalence QuoteEquivalence) *Multiplexor {
+	m.equivalence = equivalence
+	return m
+}
+
+// WithConverter makes multiplexor convert prices quoted in other assets, e.g. BTC_USDT into BTC_USD.
+// Prices are dropped while conversion rate is unknown or too old. Must be called before subscription.
+func (m *Multiplexor) WithConverter(converter *QuoteConverter) *Multiplexor {
+	m.converter = converter
+	return m
+}
+
+// WithDedup makes multiplexor drop repeated prints seen within the window, at most maxEntries prints are
+// remembered (unlimited if 0). Must be called before subscription.
+func (m *Multiplexor) WithDedup(window time.Duration, maxEntries int) *Multiplexor {
+	m.dedup = newDeduplicator(window, maxEntries)
+	return m
+}
+
+// WithResubscribeOnGap makes multiplexor subscribe the source again when a gap in sequence numbers is detected.
+// Must be called before subscription.
+func (m *Multiplexor) WithResubscribeOnGap() *Multiplexor {
+	m.resubscribe = true
+	return m
+}
+
+// WithCircuitBreaker quarantines sources with repeated parse errors, deviations or broken streams for a cooldown.
+// Prices of quarantined sources are dropped, broken streams are subscribed again instead of being removed.
+// Finished streams, reported with ErrStreamClosed, are removed as usual.
+// Must be called before subscription.
+func (m *Multiplexor) WithCircuitBreaker(opts BreakerOptions) *Multiplexor {
+	m.breaker = &opts
+	m.references = map[string]breakerReference{}
+	return m
 }
 
+// WithRateLimit makes multiplexor deliver prices to output through per-source queues in round-robin order,
+// so no single source dominates output. Prices of each source are optionally rate limited and coalesced.
+// Must be called before subscription.
+func (m *Multiplexor) WithRateLimit(opts RateLimitOptions) *Multiplexor {
+	m.rateLimit = &opts
+	return m
+}
+
+// WithMetrics enables instrumentation. Must be called before subscription.
+func (m *Multiplexor) WithMetrics(metrics *Metrics) *Multiplexor {
+	m.metrics = metrics
+	return m
+}
+
+// Subscribe combines legacy subscribers. Streams live until they report an error.
 func (m *Multiplexor) Subscribe(apis []IPriceStreamSubscriber) chan TickerPrice { // TODO: not sure we have to return channel here
-	if len(apis) == 0 {
-		result := make(chan TickerPrice, 1)
-		close(result)
-		return result
-	}
-	wg := &sync.WaitGroup{}
-	wg.Add(len(apis))
-	output := make(chan TickerPrice, 1)
-	for _, api := range apis {
-		priceCh, errCh := api.SubscribePriceStream(BTCUSDTicker)
-		// goroutine per channel, thanks it's lightweight
-		go runStream(output, priceCh, errCh, wg)
+	return m.SubscribeContext(context.Background(), AdaptSubscribers(apis))
+}
+
+// SubscribeContext combines subscribers into single channel. Sources are named by their index.
+// All subscriptions are closed and output channel is closed as soon as ctx is done.
+func (m *Multiplexor) SubscribeContext(ctx context.Context, apis []IPriceStreamSubscriberV2) chan TickerPrice {
+	sources := make([]NamedSource, 0, len(apis))
+	for i, api := range apis {
+		sources = append(sources, NamedSource{Name: fmt.Sprintf("source-%d", i), Subscriber: api})
+	}
+	return m.SubscribeSources(ctx, sources)
+}
+
+// SubscribeSources combines named sources into single channel.
+// Output channel stays opened while at least one source is alive.
+// Repeated calls add sources to the running multiplexor and return the same channel.
+func (m *Multiplexor) SubscribeSources(ctx context.Context, sources []NamedSource) chan TickerPrice {
+	m.m.Lock()
+	defer m.m.Unlock()
+	if m.done {
+		return m.output
 	}
+	if m.output == nil {
+		m.ctx = ctx
+		m.output = make(chan TickerPrice, 1)
+		if m.rateLimit != nil {
+			m.scheduler = newFairScheduler(*m.rateLimit, func(source string) {
+				m.metrics.priceCoalesced(m.ticker, source)
+			})
+			go m.scheduler.run(ctx, m.output, m.doneCh)
+		}
+	}
+	for _, src := range sources {
+		if err := m.addSource(src.Name, src.Subscriber); err != nil {
+			m.emit(SourceEvent{Type: SourceDisconnected, Source: src.Name, Err: err})
+		}
+	}
+	m.closeIfEmpty()
+	return m.output
+}
+
+// AddSource subscribes one more source on a running multiplexor
+func (m *Multiplexor) AddSource(name string, api IPriceStreamSubscriberV2) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+	if m.output == nil {
+		return ErrNotStarted
+	}
+	if m.done {
+		return ErrMultiplexorDone
+	}
+	return m.addSource(name, api)
+}
+
+// RemoveSource unsubscribes the source. Output channel is closed if it was the last one.
+func (m *Multiplexor) RemoveSource(name string) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+	src, found := m.sources[name]
+	if !found {
+		return ErrSourceNotFound
+	}
+	delete(m.sources, name)
+	delete(m.references, name)
+	src.cancel()
+	if m.scheduler != nil {
+		m.scheduler.remove(name, true)
+	}
+	m.emit(SourceEvent{Type: SourceRemoved, Source: name})
+	return nil
+}
+
+// Sources returns sorted names of alive sources
+func (m *Multiplexor) Sources() []string {
+	m.m.Lock()
+	defer m.m.Unlock()
+	result := make([]string, 0, len(m.sources))
+	for name := range m.sources {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Duplicates returns number of dropped duplicates by source
+func (m *Multiplexor) Duplicates() map[string]int {
+	m.m.Lock()
+	defer m.m.Unlock()
+	result := make(map[string]int, len(m.duplicates))
+	for source, n := range m.duplicates {
+		result[source] = n
+	}
+	return result
+}
+
+// Breakers returns states of circuit breakers of alive sources, empty if breaker is disabled
+func (m *Multiplexor) Breakers() map[string]BreakerState {
+	m.m.Lock()
+	defer m.m.Unlock()
+	result := map[string]BreakerState{}
+	for name, src := range m.sources {
+		if src.breaker != nil {
+			result[name] = src.breaker.state
+		}
+	}
+	return result
+}
+
+// Wait blocks until all stream goroutines are finished and output channel is closed
+func (m *Multiplexor) Wait() {
+	<-m.doneCh
+}
+
+// Events returns channel of source membership changes. It's closed together with output channel.
+// Events are dropped if nobody reads them.
+func (m *Multiplexor) Events() <-chan SourceEvent {
+	return m.events
+}
+
+func (m *Multiplexor) addSource(name string, api IPriceStreamSubscriberV2) error {
+	if name == "" {
+		return ErrSourceNameEmpty
+	}
+	if api == nil {
+		return ErrSubscriberIsNil
+	}
+	if _, found := m.sources[name]; found {
+		return ErrSourceExists
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	sub, err := api.SubscribePriceStreamContext(ctx, m.ticker)
+	if err != nil {
+		cancel()
+		return err
+	}
+	src := &source{cancel: cancel}
+	if m.breaker != nil {
+		src.breaker = newCircuitBreaker(*m.breaker)
+	}
+	m.sources[name] = src
+	m.running++
+	m.emit(SourceEvent{Type: SourceAdded, Source: name})
+	// goroutine per channel, thanks it's lightweight
 	go func() {
-		// don't forget to close output channel if all input channels were closed
-		wg.Wait()
-		close(output)
+		err := m.runSource(ctx, name, src, api, sub)
+		cancel()
+		m.streamDone(name, src, err)
 	}()
+	return nil
+}
 
-	return output
+// runSource runs stream of the source subscribing it again when it's required
+func (m *Multiplexor) runSource(
+	ctx context.Context,
+	name string,
+	src *source,
+	api IPriceStreamSubscriberV2,
+	sub ISubscription,
+) error {
+	for {
+		err := m.runStream(ctx, name, src, sub)
+		// finished stream isn't a failure, it's not subscribed again
+		if err != errResubscribe && (src.breaker == nil || ctx.Err() != nil || errors.Is(err, ErrStreamClosed)) {
+			return err
+		}
+		if sub, err = m.subscribeAgain(ctx, name, src, api, err); err != nil {
+			return err
+		}
+		m.emitLocked(SourceEvent{Type: SourceResubscribed, Source: name})
+	}
+}
+
+// subscribeAgain subscribes the source after the stream was stopped by reason.
+// Broken streams and failed subscriptions are failures of circuit breaker, attempts are paused while it's open.
+func (m *Multiplexor) subscribeAgain(
+	ctx context.Context,
+	name string,
+	src *source,
+	api IPriceStreamSubscriberV2,
+	reason error,
+) (ISubscription, error) {
+	for {
+		if reason != errResubscribe && src.breaker != nil {
+			if err := m.waitBreaker(ctx, name, src, reason); err != nil {
+				return nil, err
+			}
+		}
+		sub, err := api.SubscribePriceStreamContext(ctx, m.ticker)
+		if err == nil || src.breaker == nil {
+			return sub, err
+		}
+		reason = err
+	}
+}
+
+// waitBreaker registers failure of the source and waits while its breaker is open
+func (m *Multiplexor) waitBreaker(ctx context.Context, name string, src *source, reason error) error {
+	m.m.Lock()
+	before := src.breaker.state
+	now := time.Now()
+	src.breaker.failure(now)
+	m.breakerChanged(name, before, src.breaker.state, reason)
+	wait := src.breaker.wait(now)
+	m.m.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+	before = src.breaker.state
+	src.breaker.allow(time.Now())
+	m.breakerChanged(name, before, src.breaker.state, nil)
+	return nil
+}
+
+// passBreaker checks the price by circuit breaker of the source, it returns false if the price must be dropped
+func (m *Multiplexor) passBreaker(name string, src *source, price TickerPrice) bool {
+	if src.breaker == nil {
+		return true
+	}
+	value, err := parsePrice(price)
+	now := time.Now()
+
+	m.m.Lock()
+	defer m.m.Unlock()
+	before := src.breaker.state
+	allowed := src.breaker.allow(now)
+	m.breakerChanged(name, before, src.breaker.state, nil)
+	if !allowed {
+		m.metrics.breakerDropped(m.ticker, name)
+		return false
+	}
+	before = src.breaker.state
+	if err == nil && price.Book == nil {
+		err = checkDeviation(name, value, m.references, *m.breaker, now)
+	}
+	if err != nil {
+		src.breaker.failure(now)
+	} else {
+		src.breaker.success()
+		if price.Book == nil {
+			m.references[name] = breakerReference{value: value, time: now}
+		}
+	}
+	m.breakerChanged(name, before, src.breaker.state, err)
+	if err != nil {
+		m.metrics.breakerDropped(m.ticker, name)
+		return false
+	}
+	return true
+}
+
+// breakerChanged reports change of breaker state, must be called under the lock
+func (m *Multiplexor) breakerChanged(name string, before, after BreakerState, reason error) {
+	if before == after || m.done {
+		return
+	}
+	m.metrics.breakerChanged(m.ticker, name, after)
+	m.emit(SourceEvent{Type: SourceBreaker, Source: name, State: after, Err: reason})
+}
+
+func (m *Multiplexor) streamDone(name string, src *source, err error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.running--
+	if m.sources[name] == src {
+		// stream finished by itself, not removed
+		delete(m.sources, name)
+		delete(m.references, name)
+		if m.scheduler != nil {
+			m.scheduler.remove(name, false)
+		}
+		m.metrics.sourceDisconnected(m.ticker, name)
+		m.emit(SourceEvent{Type: SourceDisconnected, Source: name, Err: err})
+	}
+	m.closeIfEmpty()
+}
+
+// closeIfEmpty closes output channel if all input channels were closed
+func (m *Multiplexor) closeIfEmpty() {
+	if m.done || m.running > 0 || len(m.sources) > 0 {
+		return
+	}
+	m.done = true
+	close(m.events)
+	if m.scheduler != nil {
+		// output is closed by scheduler as soon as waiting prices are delivered
+		m.scheduler.close()
+		return
+	}
+	close(m.output)
+	close(m.doneCh)
+}
+
+// emitLocked emits event from stream goroutines, events channel is closed under the lock
+func (m *Multiplexor) emitLocked(event SourceEvent) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	if !m.done {
+		m.emit(event)
+	}
+}
+
+func (m *Multiplexor) emit(event SourceEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	select {
+	case m.events <- event:
+	default:
+		// non-blocking operation
+	}
 }
 
-func runStream(
-	output chan<- TickerPrice,
-	priceCh <-chan TickerPrice,
-	errCh <-chan error,
-	wg *sync.WaitGroup,
-) {
-	defer wg.Done()
+func (m *Multiplexor) runStream(
+	ctx context.Context,
+	name string,
+	src *source,
+	sub ISubscription,
+) error {
+	defer sub.Close()
+	var lastSeq uint64
 	for {
 		select {
-		case price, opened := <-priceCh:
+		case <-ctx.Done():
+			return ctx.Err()
+		case price, opened := <-sub.Prices():
 			if !opened {
-				return
+				return ErrStreamClosed
+			}
+			gap := m.checkSequence(name, &lastSeq, price.Seq)
+			if err := m.forward(ctx, name, src, price); err != nil {
+				return err
+			}
+			if gap && m.resubscribe {
+				return errResubscribe
+			}
+		case err := <-sub.Errors():
+			// prices sent before the error may still be buffered
+			for {
+				select {
+				case price, opened := <-sub.Prices():
+					if opened && m.forward(ctx, name, src, price) == nil {
+						continue
+					}
+				default:
+				}
+				return err
 			}
-			output <- price
-		case <-errCh:
-			return
 		}
 	}
 }
+
+// checkSequence reports gap and out-of-order events, it returns true if there is a gap.
+// Prices without sequence number aren't checked.
+func (m *Multiplexor) checkSequence(name string, last *uint64, seq uint64) bool {
+	switch {
+	case seq == 0:
+		return false
+	case *last == 0 || seq == *last+1:
+		*last = seq
+		return false
+	case seq > *last+1:
+		m.metrics.sequenceGap(m.ticker, name)
+		m.emitLocked(SourceEvent{Type: SourceSequenceGap, Source: name, Expected: *last + 1, Got: seq})
+		*last = seq
+		return true
+	default:
+		m.metrics.outOfOrder(m.ticker, name)
+		m.emitLocked(SourceEvent{Type: SourceOutOfOrder, Source: name, Expected: *last + 1, Got: seq})
+		return false
+	}
+}
+
+func (m *Multiplexor) forward(ctx context.Context, name string, src *source, price TickerPrice) error {
+	if price.Source == "" {
+		price.Source = name
+	}
+	m.metrics.priceReceived(m.ticker, name)
+	if m.dedup != nil && m.dedup.duplicate(price, time.Now()) {
+		m.duplicateDropped(name)
+		return nil
+	}
+	ticker, matched := m.matchTicker(price.Ticker)
+	if matched {
+		price.Ticker = ticker
+	} else if !m.convert(&price) {
+		return nil
+	}
+	if !m.passBreaker(name, src, price) {
+		return nil
+	}
+	if m.scheduler != nil {
+		return m.scheduler.push(ctx, name, price)
+	}
+	select {
+	case m.output <- price:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Multiplexor) duplicateDropped(name string) {
+	m.metrics.duplicateDropped(m.ticker, name)
+	m.m.Lock()
+	defer m.m.Unlock()
+	if m.duplicates == nil {
+		m.duplicates = map[string]int{}
+	}
+	m.duplicates[name]++
+}
+
+// convert converts price of another quote asset, it returns false if the price must be dropped
+func (m *Multiplexor) convert(price *TickerPrice) bool {
+	err := ErrNotConvertible
+	if own, parseErr := m.ticker.Pair(); m.converter != nil && parseErr == nil {
+		*price, err = m.converter.Convert(*price, own)
+	}
+	switch err {
+	case nil:
+		return true
+	case ErrNotConvertible:
+		m.metrics.priceUnmatched(m.ticker, price.Source)
+	default:
+		m.metrics.priceUnconverted(m.ticker, price.Source)
+	}
+	return false
+}
+
+// matchTicker checks ticker of the price written in any notation against the subscribed one.
+// It returns canonical ticker of the price, which keeps its own quote asset.
+func (m *Multiplexor) matchTicker(ticker Ticker) (Ticker, bool) {
+	if ticker == "" || ticker == m.ticker {
+		return ticker, true
+	}
+	pair, err := ticker.Pair()
+	if err != nil {
+		return ticker, false
+	}
+	own, err := m.ticker.Pair()
+	if err != nil {
+		return ticker, false
+	}
+	return pair.Ticker(), m.equivalence.Equivalent(pair, own)
+}
@@ -1,10 +1,13 @@
 package pkg
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"github.com/dshipenok/tickers/pkg/collector"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_ErrorBeforeResult_ExpectNoResult(t *testing.T) {
@@ -38,8 +41,216 @@ func Test_ErrorsAfterResult_ExpectCorrectValue(t *testing.T) {
 	select {
 	case price, opened := <-resultCh:
 		if opened {
-			assert.EqualValues(t, TickerPrice{Time: tn, Price: "1.0"}, price)
+			assert.EqualValues(t, TickerPrice{Time: tn, Price: "1.0", Source: "source-0"}, price)
 		}
 	case <-time.After(10 * time.Millisecond):
 	}
 }
+
+func Test_ContextCancelled_ExpectSubscriptionsClosed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream1, stream2 := newMockStreamV2(), newMockStreamV2()
+	m := NewMultiplexor()
+	resultCh := m.SubscribeContext(ctx, []IPriceStreamSubscriberV2{stream1, stream2})
+	cancel()
+
+	for _, closed := range []chan struct{}{stream1.closed, stream2.closed} {
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			assert.Fail(t, "subscription wasn't closed")
+		}
+	}
+	select {
+	case _, opened := <-resultCh:
+		assert.False(t, opened)
+	case <-time.After(time.Second):
+		assert.Fail(t, "output channel wasn't closed")
+	}
+}
+
+func Test_AddRemoveSources_ExpectOutputOpenedWhileSourcesAlive(t *testing.T) {
+	m := NewMultiplexor()
+	resultCh := m.SubscribeSources(context.Background(), []NamedSource{{Name: "a", Subscriber: newMockStreamV2()}})
+	require.NoError(t, m.AddSource("b", newMockStreamV2()))
+	assert.ErrorIs(t, m.AddSource("b", newMockStreamV2()), ErrSourceExists)
+	assert.Equal(t, []string{"a", "b"}, m.Sources())
+
+	require.NoError(t, m.RemoveSource("a"))
+	assert.ErrorIs(t, m.RemoveSource("a"), ErrSourceNotFound)
+	select {
+	case <-resultCh:
+		assert.Fail(t, "output channel was closed while source is alive")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	require.NoError(t, m.RemoveSource("b"))
+	select {
+	case _, opened := <-resultCh:
+		assert.False(t, opened)
+	case <-time.After(time.Second):
+		assert.Fail(t, "output channel wasn't closed")
+	}
+	assert.ErrorIs(t, m.AddSource("c", newMockStreamV2()), ErrMultiplexorDone)
+
+	events := []SourceEvent{}
+	for event := range m.Events() {
+		event.Time = time.Time{}
+		events = append(events, event)
+	}
+	assert.Equal(t, []SourceEvent{
+		{Type: SourceAdded, Source: "a"},
+		{Type: SourceAdded, Source: "b"},
+		{Type: SourceRemoved, Source: "a"},
+		{Type: SourceRemoved, Source: "b"},
+	}, events)
+}
+
+func Test_Multiplexor_MatchesTickers(t *testing.T) {
+	streams := func() []IPriceStreamSubscriber {
+		return valuesToStreams([][]interface{}{{
+			&TickerPrice{Ticker: "XBT/USD", Price: "1"},
+			&TickerPrice{Ticker: "BTCUSDT", Price: "2"},
+			&TickerPrice{Ticker: "ETH_USD", Price: "3"},
+			"Disconnected",
+		}})
+	}
+	var result []TickerPrice
+	for price := range NewMultiplexor().Subscribe(streams()) {
+		result = append(result, price)
+	}
+	require.Len(t, result, 1)
+	assert.Equal(t, BTCUSDTicker, result[0].Ticker, "ticker is converted to canonical form")
+
+	result = nil
+	for price := range NewMultiplexor().WithQuoteEquivalence(QuoteEquivalence{"USDT": "USD"}).Subscribe(streams()) {
+		result = append(result, price)
+	}
+	require.Len(t, result, 2)
+	assert.Equal(t, Ticker("BTC_USDT"), result[1].Ticker, "quote asset is kept")
+}
+
+func Test_Multiplexor_SequenceEvents(t *testing.T) {
+	m := NewMultiplexor()
+	output := m.SubscribeSources(context.Background(), []NamedSource{{Name: "a", Subscriber: &mockSequenceStream{
+		subscriptions: [][]TickerPrice{{{Seq: 1}, {Seq: 2}, {Seq: 5}, {Seq: 4}, {Seq: 6}}},
+	}}})
+	n := 0
+	for range output {
+		n++
+	}
+	assert.Equal(t, 5, n, "prices are forwarded anyway")
+
+	var events []SourceEvent
+	for event := range m.Events() {
+		if event.Type == SourceSequenceGap || event.Type == SourceOutOfOrder {
+			events = append(events, SourceEvent{Type: event.Type, Source: event.Source, Expected: event.Expected, Got: event.Got})
+		}
+	}
+	assert.Equal(t, []SourceEvent{
+		{Type: SourceSequenceGap, Source: "a", Expected: 3, Got: 5},
+		{Type: SourceOutOfOrder, Source: "a", Expected: 6, Got: 4},
+	}, events)
+}
+
+func Test_Multiplexor_ResubscribeOnGap(t *testing.T) {
+	stream := &mockSequenceStream{subscriptions: [][]TickerPrice{
+		{{Seq: 1, Price: "1"}, {Seq: 3, Price: "3"}, {Seq: 4, Price: "4"}},
+		{{Seq: 10, Price: "10"}},
+	}}
+	m := NewMultiplexor().WithResubscribeOnGap()
+	output := m.SubscribeSources(context.Background(), []NamedSource{{Name: "a", Subscriber: stream}})
+	var result []string
+	for price := range output {
+		result = append(result, price.Price)
+	}
+	assert.Equal(t, []string{"1", "3", "10"}, result, "the price after the gap is forwarded before resubscribing")
+	assert.Equal(t, 2, stream.subscribed)
+
+	resubscribed := false
+	for event := range m.Events() {
+		resubscribed = resubscribed || event.Type == SourceResubscribed
+	}
+	assert.True(t, resubscribed)
+}
+
+func Test_Multiplexor_BreakerQuarantinesSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockSequenceStream{subscriptions: [][]TickerPrice{
+		{{Price: "1"}, {Price: "x"}, {Price: "y"}, {Price: "2"}},
+	}}
+	m := NewMultiplexor().WithCircuitBreaker(BreakerOptions{Failures: 2, Window: time.Minute, Cooldown: time.Hour})
+	output := m.SubscribeSources(ctx, []NamedSource{{Name: "a", Subscriber: stream}})
+
+	price := <-output
+	assert.Equal(t, "1", price.Price)
+	for event := range m.Events() {
+		if event.Type == SourceBreaker {
+			assert.Equal(t, BreakerOpen, event.State)
+			assert.ErrorIs(t, event.Err, ErrPriceInvalid)
+			break
+		}
+	}
+	assert.Equal(t, map[string]BreakerState{"a": BreakerOpen}, m.Breakers())
+	assert.Equal(t, []string{"a"}, m.Sources(), "broken stream isn't removed")
+
+	cancel()
+	for price := range output {
+		assert.Fail(t, "price of quarantined source", price)
+	}
+}
+
+func Test_Multiplexor_BreakerResubscribesBrokenStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &mockSequenceStream{subscriptions: [][]TickerPrice{
+		{{Price: "1"}}, {{Price: "2"}}, {{Price: "3"}},
+	}}
+	m := NewMultiplexor().WithCircuitBreaker(BreakerOptions{Failures: 5, Window: time.Minute, Cooldown: time.Hour})
+	output := m.SubscribeSources(ctx, []NamedSource{{Name: "a", Subscriber: stream}})
+
+	var result []string
+	for len(result) < 3 {
+		result = append(result, (<-output).Price)
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, result)
+
+	var states []BreakerState
+	resubscribed := 0
+	for event := range m.Events() {
+		switch event.Type {
+		case SourceResubscribed:
+			resubscribed++
+		case SourceBreaker:
+			states = append(states, event.State)
+		}
+		if len(states) > 0 {
+			break
+		}
+	}
+	assert.Equal(t, 2, resubscribed)
+	assert.Equal(t, []BreakerState{BreakerOpen}, states, "failed subscriptions open the breaker")
+}
+
+func Test_Multiplexor_BreakerFinishedStreamIsRemoved(t *testing.T) {
+	tn := time.Now()
+	stream := NewReplayStream([]PriceRecord{
+		{Ticker: BTCUSDTicker, Source: "a", Time: tn, Price: "1"},
+		{Ticker: BTCUSDTicker, Source: "a", Time: tn.Add(time.Millisecond), Price: "2"},
+	}, 1)
+	m := NewMultiplexor().WithCircuitBreaker(BreakerOptions{Failures: 5, Window: time.Minute, Cooldown: time.Hour})
+	output := m.SubscribeSources(context.Background(), []NamedSource{{Name: "a", Subscriber: stream}})
+	p := NewFairPrice(collector.NewLatest(3), time.Now).WithFinalFlush()
+
+	done := make(chan []FairPriceResult)
+	go func() {
+		done <- startFairPrice(context.Background(), p, output, time.Hour)
+	}()
+	select {
+	case result := <-done:
+		require.Len(t, result, 1)
+		assert.Equal(t, "2.000", result[0].Price)
+	case <-time.After(time.Second):
+		assert.Fail(t, "finished stream was subscribed again")
+	}
+}
@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+)
+
+// PriceRecord is a serialized source price, one JSON object per line
+type PriceRecord struct {
+	Ticker  Ticker          `json:"ticker"`
+	Source  string          `json:"source"`
+	Time    time.Time       `json:"time"`
+	Price   string          `json:"price"`
+	Size    string          `json:"size,omitempty"`
+	TradeID string          `json:"trade_id,omitempty"`
+	Seq     uint64          `json:"seq,omitempty"`
+	Book    *collector.Book `json:"book,omitempty"` // quote event
+}
+
+// PriceRecorder writes source prices as JSON lines, safe for concurrent use
+type PriceRecorder struct {
+	m   sync.Mutex
+	enc *json.Encoder
+}
+
+// NewPriceRecorder constructor
+func NewPriceRecorder(w io.Writer) *PriceRecorder {
+	return &PriceRecorder{enc: json.NewEncoder(w)}
+}
+
+func (r *PriceRecorder) Record(price TickerPrice) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.enc.Encode(PriceRecord{
+		Ticker:  price.Ticker,
+		Source:  price.Source,
+		Time:    price.Time,
+		Price:   price.Price,
+		Size:    price.Size,
+		TradeID: price.TradeID,
+		Seq:     price.Seq,
+		Book:    price.Book,
+	})
+}
+
+// ReadPriceRecords reads all records written by PriceRecorder sorted by time
+func ReadPriceRecords(r io.Reader) ([]PriceRecord, error) {
+	var result []PriceRecord
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var record PriceRecord
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.Before(result[j].Time) })
+	return result, nil
+}
+
+// ReplayStream replays recorded prices keeping intervals between them.
+// Prices are re-stamped with current time, so they are not dropped as too old.
+type ReplayStream struct {
+	records []PriceRecord
+	speed   float64
+}
+
+// NewReplayStream constructor. Speed 2 replays twice faster than recorded, records must be sorted by time.
+func NewReplayStream(records []PriceRecord, speed float64) *ReplayStream {
+	if speed <= 0 {
+		speed = 1
+	}
+	return &ReplayStream{
+		records: records,
+		speed:   speed,
+	}
+}
+
+// SubscribePriceStreamContext replays records of the ticker, ErrStreamClosed is reported when all of them are sent
+func (s *ReplayStream) SubscribePriceStreamContext(ctx context.Context, ticker Ticker) (ISubscription, error) {
+	sub := NewSubscription(ctx)
+	go func() {
+		var first time.Time
+		started := time.Now()
+		for _, record := range s.records {
+			if record.Ticker != ticker {
+				continue
+			}
+			if first.IsZero() {
+				first = record.Time
+			}
+			offset := time.Duration(float64(record.Time.Sub(first)) / s.speed)
+			timer := time.NewTimer(time.Until(started.Add(offset)))
+			select {
+			case <-sub.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			price := TickerPrice{
+				Ticker:  record.Ticker,
+				Source:  record.Source,
+				Time:    time.Now(),
+				Price:   record.Price,
+				Size:    record.Size,
+				TradeID: record.TradeID,
+				Seq:     record.Seq,
+			}
+			if record.Book != nil {
+				book := *record.Book
+				book.Time = price.Time
+				price.Book = &book
+			}
+			if !sub.SendPrice(price) {
+				return
+			}
+		}
+		sub.SendError(ErrStreamClosed)
+	}()
+	return sub, nil
+}
@@ -0,0 +1,41 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RecordReplay_QuoteEvent(t *testing.T) {
+	tn := time.Now()
+	book := &collector.Book{
+		Bids: []collector.Level{{Price: 10, Size: 1}},
+		Asks: []collector.Level{{Price: 11, Size: 2}},
+	}
+	var buf bytes.Buffer
+	recorder := NewPriceRecorder(&buf)
+	require.NoError(t, recorder.Record(TickerPrice{Ticker: BTCUSDTicker, Source: "a", Time: tn, Price: "10.5", Seq: 1}))
+	require.NoError(t, recorder.Record(TickerPrice{Ticker: BTCUSDTicker, Source: "a", Time: tn, Book: book, Seq: 2}))
+
+	records, err := ReadPriceRecords(&buf)
+	require.NoError(t, err)
+	sub, err := NewReplayStream(records, 1).SubscribePriceStreamContext(context.Background(), BTCUSDTicker)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	trade, quote := <-sub.Prices(), <-sub.Prices()
+	assert.Equal(t, "10.5", trade.Price)
+	assert.Nil(t, trade.Book)
+	assert.Equal(t, uint64(1), trade.Seq)
+	require.NotNil(t, quote.Book, "quote event isn't replayed as a trade")
+	assert.Equal(t, "", quote.Price)
+	assert.Equal(t, book.Bids, quote.Book.Bids)
+	assert.Equal(t, book.Asks, quote.Book.Asks)
+	assert.Equal(t, uint64(2), quote.Seq)
+	assert.ErrorIs(t, <-sub.Errors(), ErrStreamClosed)
+}
@@ -0,0 +1,243 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimitOptions configures per-source rate limiting and fair scheduling of multiplexor output
+type RateLimitOptions struct {
+	Interval  time.Duration // min interval between prices of a source sent to output, not limited if 0
+	Coalesce  bool          // waiting price is replaced by newer one of the same kind (trade or quote) instead of blocking
+	QueueSize int           // max waiting prices of a source without coalescing, the stream is blocked when it's full
+}
+
+// scheduledSource is a queue of prices of a single source waiting for output
+type scheduledSource struct {
+	queue []TickerPrice
+	next  time.Time     // prices aren't released before
+	space chan struct{} // closed when a price is released, created by blocked stream
+	done  bool          // stream is finished, the source is removed as soon as its queue is empty
+}
+
+// fairScheduler delivers prices of sources to output in round-robin order, so a chatty source
+// takes no more than its share of output. Each source waits for output in its own queue.
+type fairScheduler struct {
+	m sync.Mutex
+
+	opts      RateLimitOptions
+	coalesced func(source string)
+	sources   map[string]*scheduledSource
+	order     []string // round-robin order of sources
+	next      int      // index of the source to check first
+	closed    bool
+	wake      chan struct{}
+}
+
+func newFairScheduler(opts RateLimitOptions, coalesced func(source string)) *fairScheduler {
+	if opts.QueueSize < 1 {
+		opts.QueueSize = 1
+	}
+	return &fairScheduler{
+		opts:      opts,
+		coalesced: coalesced,
+		sources:   map[string]*scheduledSource{},
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// push puts the price into the queue of the source. It blocks while the queue is full unless prices are coalesced.
+func (s *fairScheduler) push(ctx context.Context, name string, price TickerPrice) error {
+	for {
+		s.m.Lock()
+		if err := ctx.Err(); err != nil {
+			// the source could be removed already
+			s.m.Unlock()
+			return err
+		}
+		src := s.source(name)
+		if s.opts.Coalesce {
+			if i := waitingOfKind(src.queue, price); i >= 0 {
+				src.queue[i] = price
+				s.m.Unlock()
+				s.coalesced(name)
+				return nil
+			}
+		}
+		if s.opts.Coalesce || len(src.queue) < s.opts.QueueSize {
+			src.queue = append(src.queue, price)
+			s.m.Unlock()
+			s.signal()
+			return nil
+		}
+		if src.space == nil {
+			src.space = make(chan struct{})
+		}
+		space := src.space
+		s.m.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-space:
+		}
+	}
+}
+
+// waitingOfKind returns index of waiting price of the same kind, -1 if there is none
+func waitingOfKind(queue []TickerPrice, price TickerPrice) int {
+	for i, waiting := range queue {
+		if (waiting.Book != nil) == (price.Book != nil) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *fairScheduler) source(name string) *scheduledSource {
+	src, found := s.sources[name]
+	if !found {
+		src = &scheduledSource{}
+		s.sources[name] = src
+		s.order = append(s.order, name)
+	}
+	return src
+}
+
+// remove forgets the source. Waiting prices are dropped, otherwise they are delivered before the source is removed.
+func (s *fairScheduler) remove(name string, drop bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	src, found := s.sources[name]
+	if !found {
+		return
+	}
+	if drop {
+		src.queue = nil
+	}
+	src.done = true
+	src.release()
+	if len(src.queue) == 0 {
+		s.delete(name)
+	}
+}
+
+func (s *fairScheduler) delete(name string) {
+	delete(s.sources, name)
+	for i, n := range s.order {
+		if n == name {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			if i < s.next {
+				s.next--
+			}
+			return
+		}
+	}
+}
+
+// close makes run finish as soon as waiting prices are delivered
+func (s *fairScheduler) close() {
+	s.m.Lock()
+	s.closed = true
+	s.m.Unlock()
+	s.signal()
+}
+
+func (s *fairScheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+		// already signalled
+	}
+}
+
+// run sends waiting prices to output until the scheduler is closed, then output and done channels are closed.
+// Waiting prices are dropped when ctx is done.
+func (s *fairScheduler) run(ctx context.Context, output chan TickerPrice, done chan struct{}) {
+	defer close(done)
+	defer close(output)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		price, wait, finished := s.pick(time.Now(), ctx.Err() != nil)
+		if finished {
+			return
+		}
+		if wait < 0 {
+			select {
+			case output <- price:
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		var timeout <-chan time.Time
+		cancelled := ctx.Done()
+		if ctx.Err() != nil {
+			// waiting prices are dropped, only closing is awaited
+			cancelled = nil
+		}
+		if wait > 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-s.wake:
+		case <-timeout:
+		case <-cancelled:
+		}
+	}
+}
+
+// pick releases the next price in round-robin order. If there is nothing to release it returns time
+// to wait for rate limited prices, 0 if there are none, and whether scheduler is finished.
+// Negative wait means the price is released.
+func (s *fairScheduler) pick(now time.Time, drop bool) (TickerPrice, time.Duration, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var wait time.Duration
+	empty := true
+	for i := range s.order {
+		index := (s.next + i) % len(s.order)
+		src := s.sources[s.order[index]]
+		if drop {
+			src.queue = nil
+			src.release()
+		}
+		if len(src.queue) == 0 {
+			continue
+		}
+		empty = false
+		if left := src.next.Sub(now); left > 0 {
+			if wait == 0 || left < wait {
+				wait = left
+			}
+			continue
+		}
+		price := src.queue[0]
+		src.queue = src.queue[1:]
+		src.next = now.Add(s.opts.Interval)
+		src.release()
+		s.next = index + 1
+		if src.done && len(src.queue) == 0 {
+			s.delete(s.order[index])
+		}
+		return price, -1, false
+	}
+	return TickerPrice{}, wait, empty && s.closed
+}
+
+// release wakes up the stream blocked on full queue
+func (src *scheduledSource) release() {
+	if src.space != nil {
+		close(src.space)
+		src.space = nil
+	}
+}
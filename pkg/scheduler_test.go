@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pickPrices(s *fairScheduler, now time.Time) []string {
+	var result []string
+	for {
+		price, wait, _ := s.pick(now, false)
+		if wait >= 0 {
+			return result
+		}
+		result = append(result, price.Source+price.Price)
+	}
+}
+
+func Test_FairScheduler_RoundRobin(t *testing.T) {
+	ctx := context.Background()
+	s := newFairScheduler(RateLimitOptions{QueueSize: 10}, nil)
+	for _, price := range []TickerPrice{
+		{Source: "a", Price: "1"}, {Source: "a", Price: "2"}, {Source: "a", Price: "3"},
+		{Source: "b", Price: "1"}, {Source: "c", Price: "1"}, {Source: "c", Price: "2"},
+	} {
+		require.NoError(t, s.push(ctx, price.Source, price))
+	}
+	assert.Equal(t, []string{"a1", "b1", "c1", "a2", "c2", "a3"}, pickPrices(s, time.Now()))
+}
+
+func Test_FairScheduler_RateLimitAndCoalesce(t *testing.T) {
+	ctx, tn := context.Background(), time.Now()
+	coalesced := 0
+	s := newFairScheduler(RateLimitOptions{Interval: time.Second, Coalesce: true}, func(string) { coalesced++ })
+	book := &collector.Book{Bids: []collector.Level{{Price: 1, Size: 1}}, Asks: []collector.Level{{Price: 2, Size: 1}}}
+	for _, price := range []TickerPrice{
+		{Source: "a", Price: "1"}, {Source: "a", Price: "2"}, {Source: "a", Book: book}, {Source: "a", Price: "3"},
+	} {
+		require.NoError(t, s.push(ctx, price.Source, price))
+	}
+	assert.Equal(t, 2, coalesced)
+	assert.Equal(t, []string{"a3"}, pickPrices(s, tn), "the latest trade, the book waits for the next interval")
+
+	_, wait, finished := s.pick(tn.Add(100*time.Millisecond), false)
+	assert.Equal(t, 900*time.Millisecond, wait)
+	assert.False(t, finished)
+	price, _, _ := s.pick(tn.Add(time.Second), false)
+	assert.Equal(t, book, price.Book)
+
+	s.close()
+	_, _, finished = s.pick(tn.Add(2*time.Second), false)
+	assert.True(t, finished)
+}
+
+func Test_FairScheduler_FullQueueBlocksStream(t *testing.T) {
+	ctx := context.Background()
+	s := newFairScheduler(RateLimitOptions{}, nil)
+	require.NoError(t, s.push(ctx, "a", TickerPrice{Price: "1"}))
+	pushed := make(chan error)
+	go func() {
+		pushed <- s.push(ctx, "a", TickerPrice{Price: "2"})
+	}()
+	select {
+	case <-pushed:
+		assert.Fail(t, "queue is full")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	price, _, _ := s.pick(time.Now(), false)
+	assert.Equal(t, "1", price.Price)
+	assert.NoError(t, <-pushed)
+}
+
+func Test_Multiplexor_RateLimitDeliversLatest(t *testing.T) {
+	prices := make([]TickerPrice, 0, 100)
+	for i := 1; i <= 100; i++ {
+		prices = append(prices, TickerPrice{Price: strconv.Itoa(i)})
+	}
+	m := NewMultiplexor().WithRateLimit(RateLimitOptions{Coalesce: true})
+	output := m.SubscribeSources(context.Background(), []NamedSource{
+		{Name: "a", Subscriber: &mockSequenceStream{subscriptions: [][]TickerPrice{prices}}},
+	})
+	<-time.After(10 * time.Millisecond)
+
+	var result []string
+	for price := range output {
+		result = append(result, price.Price)
+	}
+	require.NotEmpty(t, result)
+	assert.Less(t, len(result), 100, "prices waiting for slow consumer are coalesced")
+	assert.Equal(t, "100", result[len(result)-1])
+	m.Wait()
+}
+
+func Test_FairScheduler_RemoveSource(t *testing.T) {
+	ctx := context.Background()
+	s := newFairScheduler(RateLimitOptions{QueueSize: 10}, nil)
+	for _, price := range []TickerPrice{
+		{Source: "a", Price: "1"}, {Source: "a", Price: "2"}, {Source: "b", Price: "1"}, {Source: "c", Price: "1"},
+	} {
+		require.NoError(t, s.push(ctx, price.Source, price))
+	}
+	s.remove("a", true)
+	s.remove("c", false)
+	assert.Equal(t, []string{"b", "c"}, s.order, "removed source with waiting prices is kept until they are delivered")
+	assert.Equal(t, []string{"b1", "c1"}, pickPrices(s, time.Now()))
+	assert.Equal(t, []string{"b"}, s.order)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, s.push(cancelled, "a", TickerPrice{}), context.Canceled)
+	assert.Len(t, s.sources, 1, "removed source isn't added back by its stream")
+}
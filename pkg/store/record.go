@@ -0,0 +1,44 @@
+package store
+
+import (
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/collector"
+)
+
+// Record is a stored fair price
+type Record struct {
+	Ticker      pkg.Ticker        `json:"ticker"`
+	Time        time.Time         `json:"time"`
+	Value       *string           `json:"value"` // null if there were no prices within the period, as in API
+	Status      pkg.PriceStatus   `json:"status"`
+	PeriodStart time.Time         `json:"period_start"`
+	PeriodEnd   time.Time         `json:"period_end"`
+	Sources     int               `json:"sources"`
+	Partial     bool              `json:"partial,omitempty"`
+	Group       string            `json:"group,omitempty"`
+	Candle      *collector.Candle `json:"candle,omitempty"`
+	Impact      *collector.Impact `json:"impact,omitempty"`
+}
+
+// NewRecord converts fair price into record
+func NewRecord(price pkg.FairPriceResult) Record {
+	record := Record{
+		Ticker:      price.Ticker,
+		Time:        price.Time,
+		Status:      price.Status,
+		PeriodStart: price.PeriodStart,
+		PeriodEnd:   price.PeriodEnd,
+		Sources:     price.SourceCount,
+		Partial:     price.Partial,
+		Group:       price.Group,
+		Candle:      price.Candle,
+		Impact:      price.Impact,
+	}
+	if price.Price != collector.NoValue {
+		value := price.Price
+		record.Value = &value
+	}
+	return record
+}
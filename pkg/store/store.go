@@ -0,0 +1,364 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+)
+
+// Store keeps fair prices in append-only segment files. Every segment "NNNNNNNN.log"
+// contains JSON lines and has companion "NNNNNNNN.idx" file with fixed size entries
+// pointing to the lines, so index is loaded without parsing of records.
+//
+// Index entry: time (int64, unix nano), offset (int64), length (uint32), ticker length (uint16), ticker.
+
+const (
+	DefaultSegmentSize = 64 << 20
+
+	logExt   = ".log"
+	idxExt   = ".idx"
+	idxFixed = 8 + 8 + 4 + 2
+)
+
+var ErrClosed = errors.New("store is closed")
+
+type entry struct {
+	time    int64
+	segment int
+	offset  int64
+	length  uint32
+}
+
+type segment struct {
+	id   int
+	log  *os.File
+	idx  *os.File
+	size int64
+}
+
+// sync flushes log and index of the segment to disk
+func (seg *segment) sync() error {
+	if err := seg.log.Sync(); err != nil {
+		return err
+	}
+	return seg.idx.Sync()
+}
+
+type Store struct {
+	m sync.RWMutex
+
+	dir         string
+	segmentSize int64
+	segments    map[int]*segment
+	active      *segment
+	index       map[pkg.Ticker][]entry // sorted by time
+	closed      bool
+}
+
+// Open opens or creates store in dir. Segments are rotated when they exceed segmentSize.
+func Open(dir string, segmentSize int64) (*Store, error) {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		dir:         dir,
+		segmentSize: segmentSize,
+		segments:    map[int]*segment{},
+		index:       map[pkg.Ticker][]entry{},
+	}
+	ids, err := s.segmentIDs()
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		// the last segment could be broken by crash, its index is always rebuilt
+		if err := s.openSegment(id, i == len(ids)-1); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("segment %d: %w", id, err)
+		}
+	}
+	if len(ids) == 0 {
+		if err := s.openSegment(1, true); err != nil {
+			return nil, err
+		}
+	}
+	for _, entries := range s.index {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].time < entries[j].time })
+	}
+	return s, nil
+}
+
+func (s *Store) segmentIDs() ([]int, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, f := range files {
+		var id int
+		if f.IsDir() || !strings.HasSuffix(f.Name(), logExt) {
+			continue
+		}
+		if _, err := fmt.Sscanf(f.Name(), "%08d"+logExt, &id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (s *Store) segmentPath(id int, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%08d%s", id, ext))
+}
+
+func (s *Store) openSegment(id int, rebuild bool) error {
+	logFile, err := os.OpenFile(s.segmentPath(id, logExt), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	idxFile, err := os.OpenFile(s.segmentPath(id, idxExt), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		logFile.Close()
+		return err
+	}
+	seg := &segment{id: id, log: logFile, idx: idxFile}
+	s.segments[id] = seg
+	s.active = seg
+
+	if !rebuild {
+		if info, err := idxFile.Stat(); err == nil && info.Size() > 0 {
+			return s.loadIndex(seg)
+		}
+	}
+	return s.rebuildIndex(seg)
+}
+
+func (s *Store) loadIndex(seg *segment) error {
+	if _, err := seg.idx.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(seg.idx)
+	fixed := make([]byte, idxFixed)
+	for {
+		if _, err := io.ReadFull(r, fixed); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return err
+		}
+		ticker := make([]byte, binary.BigEndian.Uint16(fixed[20:]))
+		if _, err := io.ReadFull(r, ticker); err != nil {
+			break
+		}
+		e := entry{
+			time:    int64(binary.BigEndian.Uint64(fixed)),
+			segment: seg.id,
+			offset:  int64(binary.BigEndian.Uint64(fixed[8:])),
+			length:  binary.BigEndian.Uint32(fixed[16:]),
+		}
+		s.index[pkg.Ticker(ticker)] = append(s.index[pkg.Ticker(ticker)], e)
+		if end := e.offset + int64(e.length); end > seg.size {
+			seg.size = end
+		}
+	}
+	return nil
+}
+
+// rebuildIndex scans log file, truncates incomplete tail and rewrites index file
+func (s *Store) rebuildIndex(seg *segment) error {
+	if _, err := seg.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := seg.idx.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := seg.idx.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(seg.log)
+	idx := bufio.NewWriter(seg.idx)
+	offset := int64(0)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			break // incomplete line is dropped below
+		}
+		var record Record
+		if json.Unmarshal(line, &record) != nil {
+			break
+		}
+		e := entry{time: record.Time.UnixNano(), segment: seg.id, offset: offset, length: uint32(len(line))}
+		s.index[record.Ticker] = append(s.index[record.Ticker], e)
+		if _, err := idx.Write(encodeEntry(record.Ticker, e)); err != nil {
+			return err
+		}
+		offset += int64(len(line))
+	}
+	if err := seg.log.Truncate(offset); err != nil {
+		return err
+	}
+	seg.size = offset
+	return idx.Flush()
+}
+
+func encodeEntry(ticker pkg.Ticker, e entry) []byte {
+	buf := make([]byte, idxFixed+len(ticker))
+	binary.BigEndian.PutUint64(buf, uint64(e.time))
+	binary.BigEndian.PutUint64(buf[8:], uint64(e.offset))
+	binary.BigEndian.PutUint32(buf[16:], e.length)
+	binary.BigEndian.PutUint16(buf[20:], uint16(len(ticker)))
+	copy(buf[idxFixed:], ticker)
+	return buf
+}
+
+// Write appends fair price to the store
+func (s *Store) Write(price pkg.FairPriceResult) error {
+	record := NewRecord(price)
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if s.active.size > 0 && s.active.size+int64(len(line)) > s.segmentSize {
+		// rotated segment isn't written anymore, so it's synced only once
+		if err := s.active.sync(); err != nil {
+			return err
+		}
+		if err := s.openSegment(s.active.id+1, true); err != nil {
+			return err
+		}
+	}
+	seg := s.active
+	e := entry{time: record.Time.UnixNano(), segment: seg.id, offset: seg.size, length: uint32(len(line))}
+	if _, err := seg.log.WriteAt(line, seg.size); err != nil {
+		return err
+	}
+	if _, err := seg.idx.Write(encodeEntry(record.Ticker, e)); err != nil {
+		return err
+	}
+	seg.size += int64(len(line))
+
+	entries := s.index[record.Ticker]
+	pos := sort.Search(len(entries), func(i int) bool { return entries[i].time > e.time })
+	entries = append(entries, entry{})
+	copy(entries[pos+1:], entries[pos:])
+	entries[pos] = e
+	s.index[record.Ticker] = entries
+	return nil
+}
+
+// Query returns records of the ticker with time within [from, to]
+func (s *Store) Query(ticker pkg.Ticker, from, to time.Time) ([]Record, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	entries := s.index[ticker]
+	first := sort.Search(len(entries), func(i int) bool { return entries[i].time >= from.UnixNano() })
+	result := []Record{}
+	for _, e := range entries[first:] {
+		if e.time > to.UnixNano() {
+			break
+		}
+		record, err := s.read(e)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// At returns the latest record of the ticker published not later than t
+func (s *Store) At(ticker pkg.Ticker, t time.Time) (Record, bool, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	if s.closed {
+		return Record{}, false, ErrClosed
+	}
+	entries := s.index[ticker]
+	pos := sort.Search(len(entries), func(i int) bool { return entries[i].time > t.UnixNano() })
+	if pos == 0 {
+		return Record{}, false, nil
+	}
+	record, err := s.read(entries[pos-1])
+	return record, err == nil, err
+}
+
+// Tickers returns sorted list of stored tickers
+func (s *Store) Tickers() []pkg.Ticker {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	result := make([]pkg.Ticker, 0, len(s.index))
+	for ticker := range s.index {
+		result = append(result, ticker)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func (s *Store) read(e entry) (Record, error) {
+	buf := make([]byte, e.length)
+	if _, err := s.segments[e.segment].log.ReadAt(buf, e.offset); err != nil {
+		return Record{}, err
+	}
+	var record Record
+	err := json.NewDecoder(bytes.NewReader(buf)).Decode(&record)
+	return record, err
+}
+
+// Sync flushes active segment to disk
+func (s *Store) Sync() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.active.sync()
+}
+
+// Close syncs and closes all segments
+func (s *Store) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	var result error
+	for _, seg := range s.segments {
+		if seg == s.active {
+			if err := seg.sync(); err != nil && result == nil {
+				result = err
+			}
+		}
+		for _, f := range []*os.File{seg.log, seg.idx} {
+			if err := f.Close(); err != nil && result == nil {
+				result = err
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dshipenok/tickers/pkg"
+	"github.com/dshipenok/tickers/pkg/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tn = time.Date(2020, 1, 7, 14, 0, 0, 0, time.UTC)
+
+func price(ticker pkg.Ticker, minute int, value string) pkg.FairPriceResult {
+	return pkg.FairPriceResult{TickerPrice: pkg.TickerPrice{Ticker: ticker, Time: tn.Add(time.Duration(minute) * time.Minute), Price: value}, Status: pkg.StatusOK}
+}
+
+func values(records []Record) (result []string) {
+	for _, record := range records {
+		if record.Value == nil {
+			result = append(result, collector.NoValue)
+			continue
+		}
+		result = append(result, *record.Value)
+	}
+	return result
+}
+
+func Test_WriteQueryReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 300) // few records per segment
+	require.NoError(t, err)
+	for minute := 0; minute < 10; minute++ {
+		require.NoError(t, s.Write(price(pkg.BTCUSDTicker, minute, string(rune('a'+minute)))))
+		require.NoError(t, s.Write(price("ETH_USD", minute, "eth")))
+	}
+
+	check := func(s *Store) {
+		records, err := s.Query(pkg.BTCUSDTicker, tn.Add(2*time.Minute), tn.Add(4*time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c", "d", "e"}, values(records))
+
+		record, found, err := s.At(pkg.BTCUSDTicker, tn.Add(5*time.Minute+30*time.Second))
+		require.NoError(t, err)
+		require.True(t, found)
+		require.NotNil(t, record.Value)
+		assert.Equal(t, "f", *record.Value)
+
+		_, found, err = s.At(pkg.BTCUSDTicker, tn.Add(-time.Second))
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		assert.Equal(t, []pkg.Ticker{pkg.BTCUSDTicker, "ETH_USD"}, s.Tickers())
+	}
+	check(s)
+	require.NoError(t, s.Close())
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	assert.Greater(t, len(segments), 1, "segments are rotated")
+
+	s, err = Open(dir, 300)
+	require.NoError(t, err)
+	defer s.Close()
+	check(s)
+}
+
+func Test_Open_TruncatedTail_ExpectRecovered(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Write(price(pkg.BTCUSDTicker, 0, "1.0")))
+	require.NoError(t, s.Write(price(pkg.BTCUSDTicker, 1, "2.0")))
+	require.NoError(t, s.Close())
+
+	// emulate crash in the middle of a write
+	f, err := os.OpenFile(filepath.Join(dir, "00000001.log"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"ticker":"BTC_USD","ti`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s, err = Open(dir, 0)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Write(price(pkg.BTCUSDTicker, 2, "3.0")))
+	records, err := s.Query(pkg.BTCUSDTicker, tn, tn.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0", "2.0", "3.0"}, values(records))
+}
+
+func Test_NoValue_StoredAsNull(t *testing.T) {
+	s, err := Open(t.TempDir(), DefaultSegmentSize)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Write(price(pkg.BTCUSDTicker, 0, collector.NoValue)))
+
+	record, found, err := s.At(pkg.BTCUSDTicker, tn)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Nil(t, record.Value)
+	data, err := json.Marshal(record)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"value":null`)
+}
@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStreamClosed is reported by adapted legacy subscribers when their price channel was closed
+var ErrStreamClosed = errors.New("price stream closed")
+
+// Subscription is a basic ISubscription implementation for stream producers
+type Subscription struct {
+	priceCh chan TickerPrice
+	errCh   chan error
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewSubscription constructor. Subscription is closed automatically when ctx is done.
+func NewSubscription(ctx context.Context) *Subscription {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Subscription{
+		priceCh: make(chan TickerPrice, 1),
+		errCh:   make(chan error),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (s *Subscription) Prices() <-chan TickerPrice {
+	return s.priceCh
+}
+
+func (s *Subscription) Errors() <-chan error {
+	return s.errCh
+}
+
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+// Done is closed when subscription was closed or its context is done
+func (s *Subscription) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// SendPrice delivers price to the consumer. Returns false if subscription was closed.
+func (s *Subscription) SendPrice(price TickerPrice) bool {
+	select {
+	case <-s.ctx.Done():
+		return false
+	case s.priceCh <- price:
+		return true
+	}
+}
+
+// SendError delivers error to the consumer. Returns false if subscription was closed.
+func (s *Subscription) SendError(err error) bool {
+	select {
+	case <-s.ctx.Done():
+		return false
+	case s.errCh <- err:
+		return true
+	}
+}
+
+type subscriberAdapter struct {
+	api IPriceStreamSubscriber
+}
+
+// AdaptSubscriber turns legacy IPriceStreamSubscriber into IPriceStreamSubscriberV2.
+// Legacy subscribers have no way to be stopped, so closing the subscription only
+// stops forwarding of their values.
+func AdaptSubscriber(api IPriceStreamSubscriber) IPriceStreamSubscriberV2 {
+	if v2, ok := api.(IPriceStreamSubscriberV2); ok {
+		return v2
+	}
+	return &subscriberAdapter{api: api}
+}
+
+// AdaptSubscribers adapts list of legacy subscribers
+func AdaptSubscribers(apis []IPriceStreamSubscriber) []IPriceStreamSubscriberV2 {
+	result := make([]IPriceStreamSubscriberV2, 0, len(apis))
+	for _, api := range apis {
+		result = append(result, AdaptSubscriber(api))
+	}
+	return result
+}
+
+func (a *subscriberAdapter) SubscribePriceStreamContext(ctx context.Context, ticker Ticker) (ISubscription, error) {
+	priceCh, errCh := a.api.SubscribePriceStream(ticker)
+	sub := NewSubscription(ctx)
+	go func() {
+		for {
+			// closed subscription must not take prices from the stream, they may be read by a new subscription
+			select {
+			case <-sub.Done():
+				return
+			default:
+			}
+			// buffered price was sent before the error if both are ready, error is checked only without prices
+			select {
+			case price, opened := <-priceCh:
+				if !a.forward(sub, price, opened) {
+					return
+				}
+				continue
+			default:
+			}
+			select {
+			case <-sub.Done():
+				return
+			case price, opened := <-priceCh:
+				if !a.forward(sub, price, opened) {
+					return
+				}
+			case err := <-errCh:
+				sub.SendError(err)
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// forward sends price to the subscription, it returns false if the stream is finished
+func (a *subscriberAdapter) forward(sub *Subscription, price TickerPrice, opened bool) bool {
+	if !opened {
+		sub.SendError(ErrStreamClosed)
+		return false
+	}
+	return sub.SendPrice(price)
+}
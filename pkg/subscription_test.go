@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Adapter_BufferedPriceBeforeError(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		priceCh, errCh := make(chan TickerPrice, 1), make(chan error, 1)
+		priceCh <- TickerPrice{Price: "1.0"}
+		errCh <- errors.New("disconnected")
+		sub, err := AdaptSubscriber(&mockStream{priceCh: priceCh, errCh: errCh}).
+			SubscribePriceStreamContext(context.Background(), BTCUSDTicker)
+		require.NoError(t, err)
+
+		select {
+		case price := <-sub.Prices():
+			assert.Equal(t, "1.0", price.Price)
+		case err := <-sub.Errors():
+			require.Fail(t, "error before buffered price", err.Error())
+		}
+		assert.EqualError(t, <-sub.Errors(), "disconnected")
+		sub.Close()
+	}
+}
+
+func Test_Adapter_ClosedSubscriptionDoesntTakePrices(t *testing.T) {
+	priceCh, errCh := make(chan TickerPrice, 1), make(chan error)
+	priceCh <- TickerPrice{Price: "1.0"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := AdaptSubscriber(&mockStream{priceCh: priceCh, errCh: errCh}).SubscribePriceStreamContext(ctx, BTCUSDTicker)
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, priceCh, 1, "price is left for the next subscription")
+}
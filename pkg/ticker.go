@@ -0,0 +1,146 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// knownQuotes are used to split symbols without separator, longer ones go first: "BTCUSDT" is BTC/USDT, not BTCU/SDT
+var knownQuotes = []string{"USDT", "USDC", "BUSD", "TUSD", "DAI", "USD", "EUR", "GBP", "JPY", "BTC", "ETH"}
+
+// assetAliases maps exchange specific asset codes to canonical ones
+var assetAliases = map[string]string{
+	"XBT": "BTC",
+	"XDG": "DOGE",
+}
+
+// Pair is a ticker parsed into base and quote assets
+type Pair struct {
+	Base  string
+	Quote string
+}
+
+// NewPair constructor, assets are converted to canonical codes
+func NewPair(base, quote string) Pair {
+	return Pair{Base: CanonicalAsset(base), Quote: CanonicalAsset(quote)}
+}
+
+// String returns canonical form of the pair, e.g. "BTC_USD"
+func (p Pair) String() string {
+	return p.Base + "_" + p.Quote
+}
+
+// Ticker returns canonical ticker of the pair
+func (p Pair) Ticker() Ticker {
+	return Ticker(p.String())
+}
+
+// Pair parses ticker in any supported notation
+func (t Ticker) Pair() (Pair, error) {
+	return ParsePair(string(t))
+}
+
+// ParsePair parses pair in canonical or common exchange notation: "BTC_USD", "XBT/USD", "btc-usd", "BTCUSDT"
+func ParsePair(s string) (Pair, error) {
+	for _, sep := range []string{"_", "/", "-"} {
+		if parts := strings.Split(s, sep); len(parts) == 2 {
+			if parts[0] == "" || parts[1] == "" {
+				break
+			}
+			return NewPair(parts[0], parts[1]), nil
+		}
+	}
+	upper := strings.ToUpper(s)
+	for _, quote := range knownQuotes {
+		if strings.HasSuffix(upper, quote) && len(upper) > len(quote) {
+			return NewPair(upper[:len(upper)-len(quote)], quote), nil
+		}
+	}
+	return Pair{}, fmt.Errorf("can't parse pair %q, expected BASE_QUOTE", s)
+}
+
+// CanonicalAsset returns upper-case asset code with exchange aliases resolved, e.g. "xbt" -> "BTC"
+func CanonicalAsset(asset string) string {
+	asset = strings.ToUpper(strings.TrimSpace(asset))
+	if canonical, found := assetAliases[asset]; found {
+		return canonical
+	}
+	return asset
+}
+
+// SymbolConvention describes how an exchange formats pairs
+type SymbolConvention struct {
+	Separator string
+	Lower     bool
+	Aliases   map[string]string // canonical asset to exchange one, e.g. BTC -> XBT
+}
+
+var (
+	ConventionCanonical = SymbolConvention{Separator: "_"}                                           // BTC_USD
+	ConventionConcat    = SymbolConvention{}                                                         // BTCUSDT
+	ConventionSlash     = SymbolConvention{Separator: "/", Aliases: map[string]string{"BTC": "XBT"}} // XBT/USD
+	ConventionDash      = SymbolConvention{Separator: "-", Lower: true}                              // btc-usd
+)
+
+// Conventions are known symbol conventions by name
+var Conventions = map[string]SymbolConvention{
+	"canonical": ConventionCanonical,
+	"concat":    ConventionConcat,
+	"slash":     ConventionSlash,
+	"dash":      ConventionDash,
+}
+
+// Format returns exchange symbol of the pair
+func (c SymbolConvention) Format(p Pair) string {
+	base, quote := p.Base, p.Quote
+	if alias, found := c.Aliases[base]; found {
+		base = alias
+	}
+	if alias, found := c.Aliases[quote]; found {
+		quote = alias
+	}
+	symbol := base + c.Separator + quote
+	if c.Lower {
+		return strings.ToLower(symbol)
+	}
+	return symbol
+}
+
+// Parse returns pair of exchange symbol
+func (c SymbolConvention) Parse(symbol string) (Pair, error) {
+	if c.Separator == "" {
+		return ParsePair(symbol)
+	}
+	parts := strings.Split(symbol, c.Separator)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Pair{}, fmt.Errorf("can't parse symbol %q, expected BASE%sQUOTE", symbol, c.Separator)
+	}
+	return NewPair(c.unalias(parts[0]), c.unalias(parts[1])), nil
+}
+
+func (c SymbolConvention) unalias(asset string) string {
+	asset = strings.ToUpper(asset)
+	for canonical, alias := range c.Aliases {
+		if alias == asset {
+			return canonical
+		}
+	}
+	return asset
+}
+
+// QuoteEquivalence maps quote assets to assets they are treated equal to, e.g. USDT -> USD.
+// Nil equivalence means exact matching.
+type QuoteEquivalence map[string]string
+
+// Normalize replaces quote asset of the pair by its equivalent
+func (e QuoteEquivalence) Normalize(p Pair) Pair {
+	if quote, found := e[p.Quote]; found {
+		p.Quote = quote
+	}
+	return p
+}
+
+// Equivalent reports whether pairs are the same up to quote equivalence
+func (e QuoteEquivalence) Equivalent(a, b Pair) bool {
+	return e.Normalize(a) == e.Normalize(b)
+}
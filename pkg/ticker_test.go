@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParsePair(t *testing.T) {
+	testCases := []struct {
+		symbol   string
+		expected Pair
+	}{
+		{symbol: "BTC_USD", expected: Pair{Base: "BTC", Quote: "USD"}},
+		{symbol: "XBT/USD", expected: Pair{Base: "BTC", Quote: "USD"}},
+		{symbol: "btc-usd", expected: Pair{Base: "BTC", Quote: "USD"}},
+		{symbol: "BTCUSDT", expected: Pair{Base: "BTC", Quote: "USDT"}},
+		{symbol: "ethbtc", expected: Pair{Base: "ETH", Quote: "BTC"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.symbol, func(t *testing.T) {
+			pair, err := ParsePair(tc.symbol)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, pair)
+		})
+	}
+
+	for _, symbol := range []string{"", "BTC", "USD", "BTC_", "A_B_C"} {
+		_, err := ParsePair(symbol)
+		assert.Error(t, err, symbol)
+	}
+}
+
+func Test_SymbolConventions(t *testing.T) {
+	pair := NewPair("btc", "usd")
+	assert.Equal(t, BTCUSDTicker, pair.Ticker())
+
+	expected := map[string]string{"canonical": "BTC_USD", "concat": "BTCUSD", "slash": "XBT/USD", "dash": "btc-usd"}
+	for name, convention := range Conventions {
+		symbol := convention.Format(pair)
+		assert.Equal(t, expected[name], symbol)
+		parsed, err := convention.Parse(symbol)
+		require.NoError(t, err, name)
+		assert.Equal(t, pair, parsed, name)
+	}
+}
+
+func Test_QuoteEquivalence(t *testing.T) {
+	usdt, usd := NewPair("BTC", "USDT"), NewPair("BTC", "USD")
+	assert.False(t, QuoteEquivalence(nil).Equivalent(usdt, usd))
+	assert.True(t, QuoteEquivalence{"USDT": "USD"}.Equivalent(usdt, usd))
+	assert.False(t, QuoteEquivalence{"USDT": "USD"}.Equivalent(usdt, NewPair("ETH", "USD")))
+}